@@ -0,0 +1,96 @@
+package cell_test
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	auctioneerconfig "code.cloudfoundry.org/auctioneer/cmd/auctioneer/config"
+	bbsconfig "code.cloudfoundry.org/bbs/cmd/bbs/config"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/durationjson"
+	"code.cloudfoundry.org/inigo/helpers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+	"github.com/tedsuo/ifrit/grouper"
+)
+
+var _ = Describe("Auctioneer handover", func() {
+	const (
+		lockTTL           = 5 * time.Second
+		lockRetryInterval = time.Second
+		kickTaskDuration  = time.Second
+
+		// as set by overrideConvergenceRepeatInterval
+		convergeRepeatInterval = time.Second
+	)
+
+	var (
+		ifritRuntime ifrit.Process
+		auctioneers  map[int]ifrit.Process
+	)
+
+	lockHolder := func() (int, error) {
+		return componentMaker.AuctioneerLockHolder(lgr)
+	}
+
+	withLockTTL := func(cfg *auctioneerconfig.AuctioneerConfig) {
+		cfg.LockTTL = durationjson.Duration(lockTTL)
+		cfg.LockRetryInterval = durationjson.Duration(lockRetryInterval)
+	}
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+
+		By("restarting the bbs with smaller convergeRepeatInterval and kickTaskDuration")
+		ginkgomon.Interrupt(bbsProcess)
+		bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
+			overrideConvergenceRepeatInterval,
+			func(cfg *bbsconfig.BBSConfig) {
+				cfg.KickTaskDuration = durationjson.Duration(kickTaskDuration)
+			},
+		))
+
+		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Interrupt, grouper.Members{
+			{Name: "rep", Runner: componentMaker.Rep()},
+		}))
+
+		auctioneers = map[int]ifrit.Process{
+			0: ginkgomon.Invoke(componentMaker.AuctioneerN(0, withLockTTL)),
+			1: ifrit.Background(componentMaker.AuctioneerN(1, withLockTTL)),
+		}
+		Eventually(lockHolder).Should(Equal(0))
+	})
+
+	AfterEach(func() {
+		for _, auctioneer := range auctioneers {
+			helpers.StopProcesses(auctioneer)
+		}
+		helpers.StopProcesses(ifritRuntime)
+	})
+
+	It("places tasks once the standby auctioneer takes over from a killed holder", func() {
+		task := helpers.TaskCreateRequest(
+			helpers.GenerateGuid(),
+			&models.RunAction{
+				User: "vcap",
+				Path: "true",
+			},
+		)
+
+		killed, stall := helpers.KillAuctioneerLockHolder(lgr, componentMaker, bbsClient, auctioneers, task)
+		Expect(killed).To(Equal(0))
+		delete(auctioneers, killed)
+
+		Expect(lockHolder()).To(Equal(1))
+		AddReportEntry("auction stall after the auctioneer lock holder was killed", stall.String())
+
+		// the standby takes the lock once the killed holder's expires, and the
+		// bbs retries the auction when it next kicks the pending task
+		Expect(stall).To(BeNumerically("<", lockTTL+lockRetryInterval+kickTaskDuration+convergeRepeatInterval+5*time.Second))
+	})
+})
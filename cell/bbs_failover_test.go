@@ -0,0 +1,54 @@
+package cell_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/inigo/helpers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("BBS lock failover", func() {
+	var (
+		standbyProcess ifrit.Process
+		standbyClient  bbs.InternalClient
+	)
+
+	lockHolder := func() (int, error) {
+		return componentMaker.BBSLockHolder(lgr)
+	}
+
+	BeforeEach(func() {
+		standbyProcess = ifrit.Background(componentMaker.BBSN(1))
+
+		bbsSSL := componentMaker.BBSSSLConfig()
+		var err error
+		standbyClient, err = bbs.NewClient(
+			componentMaker.BBSInstance(1).URL(),
+			bbsSSL.CACert,
+			bbsSSL.ClientCert,
+			bbsSSL.ClientKey,
+			0, 0,
+		)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		helpers.StopProcesses(standbyProcess)
+	})
+
+	It("hands the lock to the standby instance when the holder stops", func() {
+		Eventually(lockHolder).Should(Equal(0))
+		Consistently(lockHolder).Should(Equal(0))
+		Expect(standbyProcess.Ready()).NotTo(BeClosed())
+
+		bbsProcess.Signal(os.Interrupt)
+		Eventually(bbsProcess.Wait()).Should(Receive())
+
+		Eventually(lockHolder).Should(Equal(1))
+		Eventually(standbyProcess.Ready()).Should(BeClosed())
+		Eventually(func() bool { return standbyClient.Ping(lgr, "") }).Should(BeTrue())
+	})
+})
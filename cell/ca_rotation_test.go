@@ -0,0 +1,107 @@
+package cell_test
+
+import (
+	"runtime"
+
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/world"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+var _ = Describe("CA rotation", func() {
+	var (
+		cellID string
+
+		locketProcess, auctioneerProcess, repProcess ifrit.Process
+	)
+
+	// components are restarted one by one, in the order they are started
+	components := []struct {
+		name      string
+		process   *ifrit.Process
+		newRunner func() ifrit.Runner
+	}{
+		{"locket", &locketProcess, func() ifrit.Runner { return componentMaker.Locket() }},
+		{"bbs", &bbsProcess, func() ifrit.Runner { return componentMaker.BBS() }},
+		{"auctioneer", &auctioneerProcess, func() ifrit.Runner { return componentMaker.Auctioneer() }},
+		{"rep", &repProcess, func() ifrit.Runner {
+			return componentMaker.Rep(func(cfg *repconfig.RepConfig) {
+				cellID = cfg.CellID
+			})
+		}},
+	}
+
+	// expectMTLSToWork checks every connection between the components, with
+	// clients that load the current certificates.
+	expectMTLSToWork := func() {
+		bbsClient = componentMaker.BBSClient()
+		bbsServiceClient = componentMaker.BBSServiceClient(lgr)
+
+		Eventually(func() bool { return bbsClient.Ping(lgr, "") }).Should(BeTrue())
+		Eventually(helpers.LockOwnerPoller(lgr, componentMaker, world.BBSLockKey)).ShouldNot(BeEmpty())
+		Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, cellID)).Should(BeTrue())
+
+		lrp := helpers.LightweightLRPCreateRequest(componentMaker.Addresses(), helpers.GenerateGuid())
+		Expect(bbsClient.DesireLRP(lgr, "", lrp)).To(Succeed())
+		Eventually(func() int {
+			return len(helpers.RunningActualLRPs(lgr, bbsClient, lrp.ProcessGuid))
+		}).Should(Equal(1))
+	}
+
+	restartOneByOne := func() {
+		for _, component := range components {
+			By("restarting the " + component.name)
+			ginkgomon.Interrupt(*component.process)
+			*component.process = ginkgomon.Invoke(component.newRunner())
+		}
+	}
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+
+		// later specs share the certificates and expect the original CA
+		DeferCleanup(componentMaker.RestoreCertificates)
+
+		By("running locket outside of the plumbing, to restart it on its own")
+		helpers.StopProcesses(bbsProcess, plumbing)
+		plumbing = ginkgomon.Invoke(world.MakeCluster(componentMaker, world.Topology{
+			Components: map[string]world.ComponentSpec{
+				world.SQLComponent:  {},
+				world.NATSComponent: {},
+			},
+		}).Runner())
+
+		for _, component := range components {
+			*component.process = ginkgomon.Invoke(component.newRunner())
+		}
+	})
+
+	AfterEach(func() {
+		helpers.StopProcesses(repProcess, auctioneerProcess, bbsProcess, locketProcess)
+	})
+
+	It("keeps mTLS working between the BBS, rep, auctioneer and locket through every step", func() {
+		expectMTLSToWork()
+
+		By("trusting a new CA next to the old one")
+		componentMaker.RotateCA("rotated-ca")
+		restartOneByOne()
+		expectMTLSToWork()
+
+		By("reissuing every certificate with the new CA")
+		componentMaker.ReissueCertificates()
+		restartOneByOne()
+		expectMTLSToWork()
+
+		By("no longer trusting the old CA")
+		componentMaker.RetireOldCAs()
+		restartOneByOne()
+		expectMTLSToWork()
+	})
+})
@@ -0,0 +1,122 @@
+package cell_test
+
+import (
+	"runtime"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/chaos"
+	"code.cloudfoundry.org/inigo/helpers/invariants"
+	"code.cloudfoundry.org/inigo/world"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+const (
+	chaosDuration         = 30 * time.Second
+	chaosConvergenceLimit = 2 * time.Minute
+)
+
+var _ = Describe("Chaos", func() {
+	var (
+		driver       *chaos.Driver
+		chaosProcess ifrit.Process
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+
+		// killed reps leave their work on cells that are gone, and killed
+		// components may leave the BBS unconverged for longer than the grace
+		bbsInvariants.Allow(
+			invariants.UniqueRunningInstances,
+			invariants.RunningWithinDesired,
+			invariants.TasksOnPresentCells,
+			invariants.ActualLRPsOnPresentCells,
+		)
+
+		By("handing locket and the bbs over to the chaos driver")
+		helpers.StopProcesses(bbsProcess, plumbing)
+		bbsProcess = nil
+		plumbing = ginkgomon.Invoke(world.MakeCluster(componentMaker, world.Topology{
+			Components: map[string]world.ComponentSpec{
+				world.SQLComponent:  {},
+				world.NATSComponent: {},
+			},
+		}).Runner())
+
+		driver = chaos.New(chaos.Config{
+			Seed: GinkgoRandomSeed(),
+			Targets: world.MakeChaosTargets(componentMaker, world.Topology{
+				Components: map[string]world.ComponentSpec{
+					world.LocketComponent:       {},
+					world.BBSComponent:          {Overrides: map[string]interface{}{"converge_repeat_interval": "1s"}},
+					world.AuctioneerComponent:   {},
+					world.RepComponent:          {Count: 2},
+					world.RouteEmitterComponent: {},
+				},
+			}),
+			Interval:    3 * time.Second,
+			MaxDowntime: 2 * time.Second,
+		})
+		chaosProcess = ginkgomon.Invoke(driver)
+	})
+
+	AfterEach(func() {
+		if driver != nil && CurrentSpecReport().Failed() {
+			AddReportEntry("chaos actions", driver.Report())
+		}
+		helpers.StopProcesses(chaosProcess)
+	})
+
+	It("converges every LRP and task once the components stop being killed", func() {
+		processGuids := []string{}
+		for i := 0; i < 3; i++ {
+			lrp := helpers.LightweightLRPCreateRequest(componentMaker.Addresses(), helpers.GenerateGuid())
+			lrp.Instances = 2
+			Eventually(func() error {
+				return bbsClient.DesireLRP(lgr, "", lrp)
+			}).Should(Succeed())
+			processGuids = append(processGuids, lrp.ProcessGuid)
+		}
+
+		By("desiring tasks while components are killed and restarted")
+		taskGuids := []string{}
+		for deadline := time.Now().Add(chaosDuration); time.Now().Before(deadline); time.Sleep(time.Second) {
+			task := helpers.TaskCreateRequest(helpers.GenerateGuid(), &models.RunAction{
+				User: "vcap",
+				Path: "sh",
+				Args: []string{"-c", "exit 0"},
+			})
+
+			// requests fail while the bbs is down; only the accepted tasks
+			// have to complete
+			err := bbsClient.DesireTask(lgr, "", task.TaskGuid, task.Domain, task.TaskDefinition)
+			if err == nil {
+				taskGuids = append(taskGuids, task.TaskGuid)
+			}
+		}
+		Expect(driver.Actions()).NotTo(BeEmpty())
+
+		By("calming the cluster")
+		driver.Calm()
+
+		for _, processGuid := range processGuids {
+			Eventually(func() []models.ActualLRP {
+				return helpers.RunningActualLRPs(lgr, bbsClient, processGuid)
+			}, chaosConvergenceLimit).Should(HaveLen(2), "LRP %s did not converge after:\n%s", processGuid, driver.Report())
+		}
+
+		for _, taskGuid := range taskGuids {
+			Eventually(helpers.TaskStatePoller(lgr, bbsClient, taskGuid, nil), chaosConvergenceLimit).Should(
+				Equal(models.Task_Completed),
+				"task %s did not complete after:\n%s", taskGuid, driver.Report(),
+			)
+		}
+	})
+})
@@ -51,6 +51,7 @@ var _ = Describe("Convergence to desired state", func() {
 		appId = helpers.GenerateGuid()
 
 		processGuid = helpers.GenerateGuid()
+		Expect(testSuite.Timeline().WatchBBS(lgr, bbsClient, processGuid)).To(Succeed())
 
 		runningLRPsPoller = func() []models.ActualLRP {
 			return helpers.ActiveActualLRPs(lgr, bbsClient, processGuid)
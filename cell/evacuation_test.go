@@ -14,6 +14,7 @@ import (
 	"code.cloudfoundry.org/guardian/gqt/runner"
 	"code.cloudfoundry.org/inigo/fixtures"
 	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/invariants"
 	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
 	"code.cloudfoundry.org/tlsconfig"
 	"github.com/tedsuo/ifrit"
@@ -55,6 +56,11 @@ var _ = Describe("Evacuation", func() {
 		}
 		processGuid = helpers.GenerateGuid()
 
+		// the evacuating instance runs beside its replacement until the
+		// evacuation timeout when garden Destroy hangs, and stays on the
+		// stopped cell afterwards
+		bbsInvariants.Allow(invariants.UniqueRunningInstances, invariants.ActualLRPsOnPresentCells)
+
 		fileServer, fileServerStaticDir := componentMaker.FileServer()
 
 		By("restarting the bbs with smaller convergeRepeatInterval")
@@ -62,6 +68,7 @@ var _ = Describe("Evacuation", func() {
 		bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 			overrideConvergenceRepeatInterval,
 		))
+		Expect(testSuite.Timeline().WatchBBS(lgr, bbsClient, processGuid)).To(Succeed())
 
 		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Kill, grouper.Members{
 			{Name: "router", Runner: componentMaker.Router()},
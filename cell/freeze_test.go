@@ -0,0 +1,195 @@
+package cell_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	auctioneerconfig "code.cloudfoundry.org/auctioneer/cmd/auctioneer/config"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/durationjson"
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/invariants"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	"code.cloudfoundry.org/tlsconfig"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+var _ = Describe("Frozen cells", func() {
+	const (
+		// the frozen cell stays present for the whole of its presence TTL,
+		// which is far longer than the auctioneer waits for its state
+		presenceTTL       = 15 * time.Second
+		cellStateTimeout  = 500 * time.Millisecond
+		evacuationTimeout = 5 * time.Second
+	)
+
+	var (
+		frozenRep      *ginkgomon.Runner
+		healthyRep     *ginkgomon.Runner
+		frozenCellID   string
+		healthyCellID  string
+		healthyRepPort string
+		frozenCell     ifrit.Process
+		healthyCell    ifrit.Process
+		auctioneer     ifrit.Process
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+
+		frozenRep = componentMaker.RepN(0, func(cfg *repconfig.RepConfig) {
+			cfg.LockTTL = durationjson.Duration(presenceTTL)
+			frozenCellID = cfg.CellID
+		})
+		healthyRep = componentMaker.RepN(1, func(cfg *repconfig.RepConfig) {
+			cfg.EvacuationTimeout = durationjson.Duration(evacuationTimeout)
+			healthyCellID = cfg.CellID
+
+			var err error
+			_, healthyRepPort, err = net.SplitHostPort(cfg.ListenAddr)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		// the cells are invoked on their own, as a group would kill the
+		// frozen cell when the evacuated one exits
+		frozenCell = ginkgomon.Invoke(frozenRep)
+		healthyCell = ginkgomon.Invoke(healthyRep)
+		auctioneer = ginkgomon.Invoke(componentMaker.Auctioneer(func(cfg *auctioneerconfig.AuctioneerConfig) {
+			cfg.CellStateTimeout = durationjson.Duration(cellStateTimeout)
+		}))
+
+		Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, frozenCellID)).Should(BeTrue())
+		Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, healthyCellID)).Should(BeTrue())
+	})
+
+	AfterEach(func() {
+		helpers.Thaw(frozenRep)
+		helpers.StopProcesses(auctioneer, frozenCell, healthyCell)
+	})
+
+	// ordinaryCellIDs lists the cells that the ordinary instances of guid are
+	// placed on, once claimed or running if running is false, and once running
+	// otherwise.
+	ordinaryCellIDs := func(guid string, running bool) func() []string {
+		return func() []string {
+			lrps, err := bbsClient.ActualLRPs(lgr, "", models.ActualLRPFilter{ProcessGuid: guid})
+			Expect(err).NotTo(HaveOccurred())
+
+			cellIDs := []string{}
+			for _, lrp := range lrps {
+				if lrp.Presence != models.ActualLRP_Ordinary || lrp.CellId == "" {
+					continue
+				}
+				if running && lrp.State != models.ActualLRPStateRunning {
+					continue
+				}
+				cellIDs = append(cellIDs, lrp.CellId)
+			}
+			return cellIDs
+		}
+	}
+
+	desireLRP := func(instances int32) string {
+		guid := helpers.GenerateGuid()
+		lrp := helpers.LightweightLRPCreateRequest(componentMaker.Addresses(), guid)
+		lrp.Instances = instances
+		err := bbsClient.DesireLRP(lgr, "", lrp)
+		Expect(err).NotTo(HaveOccurred())
+		return guid
+	}
+
+	It("places work only on the cells that answer within the cell state timeout", func() {
+		helpers.Freeze(frozenRep)
+		guid := desireLRP(2)
+
+		By("placing every instance on the healthy cell well inside the frozen cell's presence TTL")
+		Eventually(ordinaryCellIDs(guid, false), presenceTTL/3).Should(ConsistOf(healthyCellID, healthyCellID))
+
+		By("keeping the frozen cell present, so that only the cell state timeout kept work off it")
+		Consistently(helpers.CellPresencePoller(lgr, bbsServiceClient, frozenCellID), presenceTTL/3).Should(BeTrue())
+
+		Eventually(ordinaryCellIDs(guid, true)).Should(ConsistOf(healthyCellID, healthyCellID))
+	})
+
+	It("places work on a cell again once it thaws", func() {
+		thawed := helpers.FreezeFor(frozenRep, 2*time.Second)
+		Eventually(thawed).Should(BeClosed())
+
+		guid := desireLRP(2)
+		Eventually(ordinaryCellIDs(guid, true)).Should(ConsistOf(frozenCellID, healthyCellID))
+	})
+
+	It("converges the work on a frozen cell only once its presence expires", func() {
+		// the thawed cell reclaims its suspect instance beside the replacement
+		// before the bbs retires one of them
+		bbsInvariants.Allow(invariants.UniqueRunningInstances)
+
+		By("restarting the bbs with smaller convergeRepeatInterval")
+		ginkgomon.Interrupt(bbsProcess)
+		bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
+			overrideConvergenceRepeatInterval,
+		))
+
+		guid := desireLRP(2)
+		Eventually(ordinaryCellIDs(guid, true)).Should(ConsistOf(frozenCellID, healthyCellID))
+
+		helpers.Freeze(frozenRep)
+
+		By("leaving the instance on the frozen cell alone while the cell is present")
+		Consistently(ordinaryCellIDs(guid, true), presenceTTL/3).Should(ConsistOf(frozenCellID, healthyCellID))
+
+		By("replacing it on the healthy cell once the presence expires")
+		Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, frozenCellID), 2*presenceTTL).Should(BeFalse())
+		Eventually(ordinaryCellIDs(guid, true)).Should(ConsistOf(healthyCellID, healthyCellID))
+	})
+
+	It("evacuates a cell whose only peer is frozen once its evacuation timeout passes", func() {
+		// the evacuating instance stays on the exited cell until the thawed
+		// cell runs its replacement
+		bbsInvariants.Allow(invariants.ActualLRPsOnPresentCells, invariants.UniqueRunningInstances)
+
+		tlscfg, err := tlsconfig.Build(
+			tlsconfig.WithInternalServiceDefaults(),
+			tlsconfig.WithIdentityFromFile(componentMaker.RepSSLConfig().ServerCert, componentMaker.RepSSLConfig().ServerKey),
+		).Client(
+			tlsconfig.WithAuthorityFromFile(componentMaker.RepSSLConfig().CACert),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		httpClient := &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: tlscfg,
+			},
+		}
+
+		helpers.Freeze(frozenRep)
+		guid := desireLRP(1)
+		Eventually(ordinaryCellIDs(guid, true)).Should(ConsistOf(healthyCellID))
+
+		By("posting the evacuation endpoint of the healthy cell")
+		// Rep admin endpoint verifies and validate 127.0.0.1 for IP SAN
+		resp, err := httpClient.Post(fmt.Sprintf("https://127.0.0.1:%s/evacuate", healthyRepPort), "text/html", nil)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+
+		By("finding no cell to take the instance while its peer is frozen")
+		Eventually(ordinaryCellIDs(guid, false)).Should(BeEmpty())
+		Consistently(ordinaryCellIDs(guid, false), evacuationTimeout/2).Should(BeEmpty())
+
+		By("exiting once the evacuation timeout passes")
+		Eventually(healthyCell.Wait(), evacuationTimeout+10*time.Second).Should(Receive())
+
+		By("running the instance on the frozen cell once it thaws")
+		helpers.Thaw(frozenRep)
+		Eventually(ordinaryCellIDs(guid, true)).Should(ConsistOf(frozenCellID))
+	})
+})
@@ -0,0 +1,114 @@
+package cell_test
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/world"
+	"code.cloudfoundry.org/tlsconfig"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health readiness", func() {
+	// neverLogged replaces the start log line of the component under test, so
+	// that only its health check can report it ready
+	const neverLogged = "inigo.never-logged"
+
+	var (
+		healthMaker world.ComponentMaker
+		processes   []ifrit.Process
+	)
+
+	// invoke starts runner and waits for its usual start log line.
+	invoke := func(runner ifrit.Runner) {
+		processes = append([]ifrit.Process{ginkgomon.Invoke(runner)}, processes...)
+	}
+
+	// invokeHealthChecked starts runner, which never logs its start check, and
+	// waits for its health check. It then reads the runner's output, which
+	// must be available through the runner the factory returned.
+	invokeHealthChecked := func(runner ifrit.Runner) {
+		ginkgomonRunner, ok := runner.(*ginkgomon.Runner)
+		Expect(ok).To(BeTrue(), "%T is not a ginkgomon runner", runner)
+		ginkgomonRunner.StartCheck = neverLogged
+		ginkgomonRunner.StartCheckTimeout = 10 * time.Second
+
+		invoke(healthMaker.WithReadinessCheck(ginkgomonRunner))
+		Expect(ginkgomonRunner.Buffer().Contents()).NotTo(BeEmpty())
+	}
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+
+		healthMaker = componentMaker.WithReadiness(world.HealthReadiness)
+		processes = nil
+
+		By("running locket and the bbs outside of the plumbing, to start them health checked")
+		helpers.StopProcesses(bbsProcess, plumbing)
+		bbsProcess = nil
+		plumbing = ginkgomon.Invoke(world.MakeCluster(componentMaker, world.Topology{
+			Components: map[string]world.ComponentSpec{
+				world.SQLComponent:  {},
+				world.NATSComponent: {},
+			},
+		}).Runner())
+	})
+
+	AfterEach(func() {
+		helpers.StopProcesses(processes...)
+	})
+
+	It("reports locket ready once its gRPC server answers", func() {
+		invokeHealthChecked(healthMaker.Locket())
+	})
+
+	It("reports the bbs ready once it answers pings", func() {
+		invoke(componentMaker.Locket())
+		invokeHealthChecked(healthMaker.BBS())
+
+		Expect(componentMaker.BBSClient().Ping(lgr, "")).To(BeTrue())
+	})
+
+	It("reports the rep ready once its mTLS server answers pings", func() {
+		invoke(componentMaker.Locket())
+		invoke(componentMaker.BBS())
+		invokeHealthChecked(healthMaker.Rep())
+
+		tlscfg, err := tlsconfig.Build(
+			tlsconfig.WithInternalServiceDefaults(),
+			tlsconfig.WithIdentityFromFile(componentMaker.RepSSLConfig().ServerCert, componentMaker.RepSSLConfig().ServerKey),
+		).Client(
+			tlsconfig.WithAuthorityFromFile(componentMaker.RepSSLConfig().CACert),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		httpClient := &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: tlscfg,
+			},
+		}
+		resp, err := httpClient.Get("https://" + componentMaker.Addresses().Rep + "/ping")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("reports the router ready once its health endpoint answers", func() {
+		invokeHealthChecked(healthMaker.Router())
+	})
+
+	It("reports the ssh proxy ready once its health endpoint answers", func() {
+		invoke(componentMaker.Locket())
+		invoke(componentMaker.BBS())
+		invokeHealthChecked(healthMaker.SSHProxy())
+	})
+})
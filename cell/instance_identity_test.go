@@ -72,7 +72,7 @@ var _ = Describe("InstanceIdentity", func() {
 		organizationalUnit = []string{"jim:radical"}
 
 		var err error
-		credDir := world.TempDirWithParent(suiteTempDir, "instance-creds")
+		credDir := world.TempDirWithParent(testSuite.TempDir(), "instance-creds")
 
 		certAuthority, err := certauthority.NewCertAuthority(credDir, "ca-with-no-max-path-length")
 		Expect(err).NotTo(HaveOccurred())
@@ -355,7 +355,7 @@ var _ = Describe("InstanceIdentity", func() {
 				config.EnvoyConfigRefreshDelay = durationjson.Duration(time.Second)
 				config.ContainerProxyPath = filepath.Dir(os.Getenv("PROXY_BINARY"))
 
-				envoyConfigDir := world.TempDirWithParent(suiteTempDir, "envoy_config")
+				envoyConfigDir := world.TempDirWithParent(testSuite.TempDir(), "envoy_config")
 
 				config.ContainerProxyConfigPath = envoyConfigDir
 			}
@@ -860,7 +860,7 @@ var _ = Describe("InstanceIdentity", func() {
 				if runtime.GOOS == "windows" {
 					Skip("TODO: figure out a way to create .exe or .bat file that emulates the slep behavior in windows")
 				}
-				sleepyEnvoyDir = createSleepyEnvoy(suiteTempDir)
+				sleepyEnvoyDir = createSleepyEnvoy(testSuite.TempDir())
 
 				setSleepEnvoy := func(config *config.RepConfig) {
 					config.ContainerProxyPath = sleepyEnvoyDir
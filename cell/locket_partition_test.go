@@ -0,0 +1,132 @@
+package cell_test
+
+import (
+	"runtime"
+	"time"
+
+	bbsconfig "code.cloudfoundry.org/bbs/cmd/bbs/config"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/invariants"
+	"code.cloudfoundry.org/inigo/world"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+// partitionSlack is how long after a TTL expires the expiry is noticed.
+const partitionSlack = 10 * time.Second
+
+var _ = Describe("Locket partitions", func() {
+	var link *helpers.LocketLink
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+		// a partitioned rep's cell goes missing with its work on it, and its
+		// suspect instances run beside their replacements once it is back
+		bbsInvariants.Allow(
+			invariants.UniqueRunningInstances,
+			invariants.TasksOnPresentCells,
+			invariants.ActualLRPsOnPresentCells,
+		)
+		link = helpers.StartLocketLink(componentMaker)
+	})
+
+	AfterEach(func() {
+		link.Stop()
+	})
+
+	Context("when a rep is partitioned from locket", func() {
+		var (
+			partitionedCellID string
+			presenceTTL       time.Duration
+			partitionedRep    ifrit.Process
+			healthyRep        ifrit.Process
+			auctioneer        ifrit.Process
+		)
+
+		BeforeEach(func() {
+			By("restarting the bbs with smaller convergeRepeatInterval")
+			ginkgomon.Interrupt(bbsProcess)
+			bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
+				overrideConvergenceRepeatInterval,
+			))
+
+			partitionedRep = ginkgomon.Invoke(componentMaker.RepN(0, link.RepConfig, func(cfg *repconfig.RepConfig) {
+				partitionedCellID = cfg.CellID
+				presenceTTL = time.Duration(cfg.LockTTL)
+			}))
+			auctioneer = ginkgomon.Invoke(componentMaker.Auctioneer())
+
+			Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, partitionedCellID)).Should(BeTrue())
+		})
+
+		AfterEach(func() {
+			helpers.StopProcesses(auctioneer, healthyRep, partitionedRep)
+		})
+
+		It("marks the cell missing until the partition heals", func() {
+			link.Partition()
+			Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, partitionedCellID), presenceTTL+partitionSlack).Should(BeFalse())
+
+			link.Heal()
+			Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, partitionedCellID)).Should(BeTrue())
+		})
+
+		It("reschedules the cell's work on a cell that can still reach locket", func() {
+			guid := helpers.GenerateGuid()
+			lrp := helpers.LightweightLRPCreateRequest(componentMaker.Addresses(), guid)
+			err := bbsClient.DesireLRP(lgr, "", lrp)
+			Expect(err).NotTo(HaveOccurred())
+
+			var actualLRP models.ActualLRP
+			Eventually(helpers.LRPStatePoller(lgr, bbsClient, guid, &actualLRP)).Should(Equal(models.ActualLRPStateRunning))
+			Expect(actualLRP.CellId).To(Equal(partitionedCellID))
+
+			var healthyCellID string
+			healthyRep = ginkgomon.Invoke(componentMaker.RepN(1, func(cfg *repconfig.RepConfig) {
+				healthyCellID = cfg.CellID
+			}))
+			Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, healthyCellID)).Should(BeTrue())
+
+			link.Partition()
+			Eventually(helpers.CellPresencePoller(lgr, bbsServiceClient, partitionedCellID), presenceTTL+partitionSlack).Should(BeFalse())
+
+			Eventually(func() []string {
+				cellIDs := []string{}
+				for _, lrp := range helpers.RunningActualLRPs(lgr, bbsClient, guid) {
+					cellIDs = append(cellIDs, lrp.CellId)
+				}
+				return cellIDs
+			}).Should(ConsistOf(healthyCellID))
+		})
+	})
+
+	Context("when the bbs is partitioned from locket", func() {
+		var (
+			lockOwner string
+			lockTTL   time.Duration
+		)
+
+		BeforeEach(func() {
+			ginkgomon.Interrupt(bbsProcess)
+			bbsProcess = ginkgomon.Invoke(componentMaker.BBS(link.BBSConfig, func(cfg *bbsconfig.BBSConfig) {
+				lockOwner = cfg.UUID
+				lockTTL = time.Duration(cfg.LockTTL)
+			}))
+
+			Eventually(helpers.LockOwnerPoller(lgr, componentMaker, world.BBSLockKey)).Should(Equal(lockOwner))
+		})
+
+		It("loses the bbs lock once it expires and exits", func() {
+			link.Partition()
+
+			Eventually(helpers.LockOwnerPoller(lgr, componentMaker, world.BBSLockKey), lockTTL+partitionSlack).ShouldNot(Equal(lockOwner))
+			Eventually(bbsProcess.Wait()).Should(Receive())
+		})
+	})
+})
@@ -1,68 +1,52 @@
 package cell_test
 
 import (
-	"os"
+	"fmt"
+	"net/http"
 	"path/filepath"
 	"runtime"
-	"strings"
+	"time"
 
 	"code.cloudfoundry.org/archiver/extractor/test_helper"
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/inigo/fixtures"
 	"code.cloudfoundry.org/inigo/helpers"
-	"code.cloudfoundry.org/lager/v3/lagertest"
+	"code.cloudfoundry.org/inigo/helpers/invariants"
+	"code.cloudfoundry.org/inigo/helpers/lagerlog"
+	"code.cloudfoundry.org/inigo/helpers/tracing"
+	"code.cloudfoundry.org/inigo/world"
+	"code.cloudfoundry.org/tlsconfig"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
-	"github.com/onsi/gomega/gbytes"
 	"github.com/tedsuo/ifrit"
 	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
-	"github.com/tedsuo/ifrit/grouper"
+	"golang.org/x/crypto/ssh"
 )
 
 var _ = Describe("TraceId", func() {
 	var (
-		fileServerStaticDir string
-		fileServer          ifrit.Runner
-		ifritRuntime        ifrit.Process
-		lrp                 *models.DesiredLRP
-		testSink            *lagertest.TestSink
-		processGuid         string
-		rep                 *ginkgomon.Runner
-		auctioneer          *ginkgomon.Runner
-		router              *ginkgomon.Runner
-		routeEmitter        *ginkgomon.Runner
-		requestId           string
-		loggedRequestId     string
+		cluster      *world.Cluster
+		ifritRuntime ifrit.Process
+		trace        *tracing.Trace
 	)
 
 	BeforeEach(func() {
 		if runtime.GOOS == "windows" {
 			Skip(" not yet supported on windows")
 		}
-		requestId = "0bc29108-c522-4360-93dd-30ca38cce13d"
-		loggedRequestId = strings.ReplaceAll(requestId, "-", "")
-		fileServer, fileServerStaticDir = componentMaker.FileServer()
+		cluster = world.MakeClusterFromFile(componentMaker, filepath.Join("..", "fixtures", "topologies", "trace-id.yml"))
 		test_helper.CreateZipArchive(
-			filepath.Join(fileServerStaticDir, "lrp.zip"),
+			filepath.Join(cluster.FileServerStaticDir, "lrp.zip"),
 			fixtures.GoServerApp(),
 		)
-		rep = componentMaker.Rep()
-		auctioneer = componentMaker.Auctioneer()
-		router = componentMaker.Router()
-		routeEmitter = componentMaker.RouteEmitter()
-		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Kill, grouper.Members{
-			{Name: "rep", Runner: rep},
-			{Name: "auctioneer", Runner: auctioneer},
-			{Name: "router", Runner: router},
-			{Name: "route-emitter", Runner: routeEmitter},
-			{Name: "file-server", Runner: fileServer},
-		}))
-		processGuid = helpers.GenerateGuid()
-		lrp = helpers.DefaultLRPCreateRequest(componentMaker.Addresses(), processGuid, "log-guid", 1)
-		err := bbsClient.DesireLRP(lgr, requestId, lrp)
-		Expect(err).NotTo(HaveOccurred())
-		testSink = lagertest.NewTestSink()
-		lgr.RegisterSink(testSink)
+		ifritRuntime = ginkgomon.Invoke(cluster.Runner())
+
+		trace = tracing.New()
+		trace.Register("bbs", bbsRunner)
+		trace.Register("auctioneer", cluster.Auctioneer)
+		trace.Register("rep", cluster.Rep())
+		trace.Register("route-emitter", cluster.RouteEmitter())
+		trace.Register("garden", gardenRunner.Runner)
 	})
 
 	AfterEach(func() {
@@ -70,11 +54,109 @@ var _ = Describe("TraceId", func() {
 	})
 
 	It("logs request trace id", func() {
+		processGuid := helpers.GenerateGuid()
+		lrp := helpers.DefaultLRPCreateRequest(componentMaker.Addresses(), processGuid, "log-guid", 1)
+		err := trace.Issue(func(requestID string) error {
+			return bbsClient.DesireLRP(lgr, requestID, lrp)
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)).Should(Equal(models.ActualLRPStateRunning))
+		trace.ExpectPropagatedTo("bbs", "auctioneer", "rep", "route-emitter", "garden")
+	})
+
+	It("logs the trace id of a task", func() {
+		task := helpers.TaskCreateRequest(
+			helpers.GenerateGuid(),
+			&models.RunAction{
+				User: "vcap",
+				Path: "true",
+			},
+		)
+		err := trace.Issue(func(requestID string) error {
+			return bbsClient.DesireTask(lgr, requestID, task.TaskGuid, task.Domain, task.TaskDefinition)
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(helpers.TaskStatePoller(lgr, bbsClient, task.TaskGuid, nil)).Should(Equal(models.Task_Completed))
+		trace.ExpectPropagatedTo("bbs", "auctioneer", "rep", "garden")
+	})
+
+	It("logs the trace id of an evacuation", func() {
+		// the rep has no other cell to evacuate to and exits, leaving the
+		// cluster stopped for the rest of the spec
+		bbsInvariants.Allow(invariants.ActualLRPsOnPresentCells)
+
+		tlscfg, err := tlsconfig.Build(
+			tlsconfig.WithInternalServiceDefaults(),
+			tlsconfig.WithIdentityFromFile(componentMaker.RepSSLConfig().ServerCert, componentMaker.RepSSLConfig().ServerKey),
+		).Client(
+			tlsconfig.WithAuthorityFromFile(componentMaker.RepSSLConfig().CACert),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		httpClient := &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: tlscfg,
+			},
+		}
+
+		err = trace.Issue(func(requestID string) error {
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://%s/evacuate", componentMaker.Addresses().Rep), nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("X-Vcap-Request-Id", requestID)
+
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusAccepted {
+				return fmt.Errorf("evacuate returned %d", resp.StatusCode)
+			}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		trace.ExpectPropagatedTo("rep")
+	})
+
+	It("logs the trace id of an ssh connection in the bbs", func() {
+		sshProxy, ok := cluster.SSHProxy.(*ginkgomon.Runner)
+		Expect(ok).To(BeTrue(), "ssh-proxy runner is not a ginkgomon runner")
+
+		processGuid := helpers.GenerateGuid()
+		lrp := helpers.DefaultLRPCreateRequest(componentMaker.Addresses(), processGuid, "log-guid", 1)
+		Expect(bbsClient.DesireLRP(lgr, "", lrp)).To(Succeed())
 		Eventually(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)).Should(Equal(models.ActualLRPStateRunning))
-		Expect(bbsRunner).To(gbytes.Say(`"trace-id":"` + loggedRequestId + `"`))
-		Expect(auctioneer).To(gbytes.Say(`"trace-id":"` + loggedRequestId + `"`))
-		Expect(rep).To(gbytes.Say(`"trace-id":"` + loggedRequestId + `"`))
-		Expect(routeEmitter).To(gbytes.Say(`"trace-id":"` + loggedRequestId + `"`))
-		Expect(gardenRunner.Runner).To(gbytes.Say(`"trace-id":"` + loggedRequestId + `"`))
+
+		// the lrp has no ssh route, so the proxy looks it up in the bbs and
+		// then refuses the connection
+		_, err := ssh.Dial("tcp", componentMaker.Addresses().SSHProxy, &ssh.ClientConfig{
+			User:            fmt.Sprintf("diego:%s/0", processGuid),
+			Auth:            []ssh.AuthMethod{ssh.Password("")},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+		Expect(err).To(HaveOccurred())
+
+		var traceID string
+		Eventually(func() string {
+			entries, err := lagerlog.Entries(sshProxy)
+			Expect(err).NotTo(HaveOccurred())
+			for _, entry := range entries {
+				if id, ok := entry.Data["trace-id"].(string); ok && id != "" {
+					traceID = id
+					break
+				}
+			}
+			return traceID
+		}).ShouldNot(BeEmpty(), "ssh-proxy did not log a trace id")
+
+		followed := tracing.Follow(traceID)
+		followed.Register("ssh-proxy", sshProxy)
+		followed.Register("bbs", bbsRunner)
+		followed.ExpectPropagatedTo("ssh-proxy", "bbs")
 	})
 })
@@ -0,0 +1,138 @@
+package cell_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	archive_helper "code.cloudfoundry.org/archiver/extractor/test_helper"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/fixtures"
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/invariants"
+	"code.cloudfoundry.org/inigo/world"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+	"github.com/tedsuo/ifrit/grouper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Upgrade", func() {
+	var (
+		ifritRuntime ifrit.Process
+		upgrade      *world.Upgrade
+
+		processGuid string
+		taskGuid    string
+	)
+
+	routeCheck := func(component string) error {
+		code, err := helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost)()
+		if err != nil {
+			return fmt.Errorf("routing to %s while upgrading %s: %w", helpers.DefaultHost, component, err)
+		}
+		if code != http.StatusOK {
+			return fmt.Errorf("routing to %s while upgrading %s: got status %d", helpers.DefaultHost, component, code)
+		}
+		return nil
+	}
+
+	// taskCheck fails once the long-running task is lost, or if it stops
+	// running while the bbs or the auctioneer is replaced. Replacing the rep
+	// it runs on fails it, and the bbs cannot be asked while it is replaced;
+	// the upgrade checks that the task's state never goes backwards.
+	taskCheck := func(component string) error {
+		task, err := bbsClient.TaskByGuid(lgr, "", taskGuid)
+		if models.ErrResourceNotFound.Equal(err) {
+			return fmt.Errorf("task %s was lost while upgrading %s", taskGuid, component)
+		}
+		if err != nil {
+			return nil
+		}
+
+		if (component == world.BBSComponent || component == world.AuctioneerComponent) && task.State != models.Task_Running {
+			return fmt.Errorf("task %s is %s while upgrading %s", taskGuid, task.State, component)
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+		processGuid = helpers.GenerateGuid()
+		taskGuid = helpers.GenerateGuid()
+
+		// replaced reps evacuate their instances, which run beside their
+		// replacements, and stop while their work is still on them
+		bbsInvariants.Allow(
+			invariants.UniqueRunningInstances,
+			invariants.TasksOnPresentCells,
+			invariants.ActualLRPsOnPresentCells,
+		)
+
+		By("handing the bbs over to the upgrade")
+		helpers.StopProcesses(bbsProcess)
+		bbsProcess = nil
+
+		fileServer, fileServerStaticDir := componentMaker.FileServer()
+		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Kill, grouper.Members{
+			{Name: "router", Runner: componentMaker.Router()},
+			{Name: "file-server", Runner: fileServer},
+		}))
+
+		archive_helper.CreateZipArchive(
+			filepath.Join(fileServerStaticDir, "lrp.zip"),
+			fixtures.GoServerApp(),
+		)
+
+		upgrade = world.MakeUpgrade(lgr, testSuite.V0ComponentMaker(), componentMaker, 2, routeCheck, taskCheck)
+		Expect(upgrade.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		if upgrade != nil {
+			upgrade.Stop()
+		}
+		helpers.StopProcesses(ifritRuntime)
+	})
+
+	It("keeps the LRPs routable and a running task intact while every component is replaced", func() {
+		lrp := helpers.DefaultLRPCreateRequest(componentMaker.Addresses(), processGuid, "log-guid", 2)
+		lrp.Setup = nil
+		lrp.CachedDependencies = []*models.CachedDependency{{
+			From:      fmt.Sprintf("http://%s/v1/static/%s", componentMaker.Addresses().FileServer, "lrp.zip"),
+			To:        "/tmp/diego",
+			Name:      "lrp bits",
+			CacheKey:  "lrp-cache-key",
+			LogSource: "APP",
+		}}
+		lrp.Privileged = true
+		Expect(bbsClient.DesireLRP(lgr, "", lrp)).To(Succeed())
+
+		task := helpers.TaskCreateRequest(taskGuid, &models.RunAction{
+			User: "vcap",
+			Path: "sh",
+			Args: []string{"-c", "sleep 3600"},
+		})
+		Expect(bbsClient.DesireTask(lgr, "", task.TaskGuid, task.Domain, task.TaskDefinition)).To(Succeed())
+
+		Eventually(func() []models.ActualLRP {
+			return helpers.RunningActualLRPs(lgr, bbsClient, processGuid)
+		}).Should(HaveLen(2))
+		Eventually(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost)).Should(Equal(http.StatusOK))
+		Eventually(helpers.TaskStatePoller(lgr, bbsClient, taskGuid, nil)).Should(Equal(models.Task_Running))
+
+		By("replacing every component")
+		Expect(upgrade.Run()).To(Succeed())
+
+		Eventually(func() []models.ActualLRP {
+			return helpers.RunningActualLRPs(lgr, bbsClient, processGuid)
+		}).Should(HaveLen(2))
+		Expect(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost)()).To(Equal(http.StatusOK))
+	})
+})
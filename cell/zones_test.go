@@ -0,0 +1,71 @@
+package cell_test
+
+import (
+	"runtime"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/world"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+var _ = Describe("Availability zones", func() {
+	var (
+		guid         string
+		cells        *world.Cells
+		cellsProcess ifrit.Process
+		auctioneer   ifrit.Process
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+		guid = helpers.GenerateGuid()
+
+		cells = world.MakeCells(componentMaker, []world.CellSpec{
+			{Zone: "z1"},
+			{Zone: "z1"},
+			{Zone: "z2"},
+			{Zone: "z2"},
+		})
+		cellsProcess = ginkgomon.Invoke(cells.Runner())
+		auctioneer = ginkgomon.Invoke(componentMaker.Auctioneer())
+	})
+
+	AfterEach(func() {
+		helpers.StopProcesses(auctioneer, cellsProcess)
+	})
+
+	It("advertises the zone of each cell", func() {
+		// the bbs lists each rep only once its presence reaches locket
+		var presences []*models.CellPresence
+		Eventually(func() []*models.CellPresence {
+			var err error
+			presences, err = bbsClient.Cells(lgr, "")
+			Expect(err).NotTo(HaveOccurred())
+			return presences
+		}).Should(HaveLen(len(cells.CellIDs)))
+
+		for _, presence := range presences {
+			Expect(presence.Zone).To(Equal(cells.ZoneOf(presence.CellId)))
+		}
+	})
+
+	It("balances the instances of an LRP across zones", func() {
+		lrp := helpers.LightweightLRPCreateRequest(componentMaker.Addresses(), guid)
+		lrp.Instances = 4
+
+		err := bbsClient.DesireLRP(lgr, "", lrp)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() int {
+			return len(helpers.RunningActualLRPs(lgr, bbsClient, guid))
+		}).Should(Equal(4))
+
+		Expect(helpers.RunningActualLRPs(lgr, bbsClient, guid)).To(helpers.BeBalancedAcrossZones(cells, 0))
+	})
+})
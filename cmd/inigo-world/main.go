@@ -0,0 +1,210 @@
+// inigo-world boots a local Diego cluster using the same ComponentFactory that
+// the inigo suites use, and keeps it running until it is interrupted.
+//
+// The executables are compiled like the suites compile them, through the
+// build cache in INIGO_BUILD_CACHE_DIR if it is set, unless -artifacts names a
+// JSON-encoded world.BuiltArtifacts file to use instead. The same environment
+// variables the suites require (GARDEN_GOPATH, ROUTER_GOPATH, GARDEN_BINPATH,
+// GROOTFS_BINPATH, GARDEN_TEST_ROOTFS, EXTERNAL_ADDRESS, DB_USER, ...) must
+// be set.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/portauthority"
+	"code.cloudfoundry.org/inigo/world"
+	"code.cloudfoundry.org/inigo/world/suite"
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/sigmon"
+)
+
+// parallelProcess stands in for the ginkgo parallel process number, which
+// names the SQL databases, cell IDs and storage paths of a world. It is well
+// above any ginkgo node number, so that a world started next to the suites
+// does not reuse their databases.
+const parallelProcess = 999
+
+// artifactsConfig lists what is compiled when no -artifacts file is given:
+// every component a topology can run.
+var artifactsConfig = suite.Config{
+	Executables: []suite.Executable{
+		suite.Garden,
+		suite.Auctioneer,
+		suite.Rep,
+		suite.BBS,
+		suite.Locket,
+		suite.FileServer,
+		suite.RouteEmitter,
+		suite.Router,
+		suite.RoutingAPI,
+		suite.SSHProxy,
+		suite.SSHD,
+	},
+	Lifecycles:  []string{"buildpackapplifecycle", "dockerapplifecycle"},
+	Healthcheck: true,
+}
+
+var defaultTopology = world.Topology{
+	Components: map[string]world.ComponentSpec{
+		world.SQLComponent:          {},
+		world.NATSComponent:         {},
+		world.GardenComponent:       {},
+		world.LocketComponent:       {},
+		world.BBSComponent:          {},
+		world.AuctioneerComponent:   {},
+		world.RepComponent:          {},
+		world.RouteEmitterComponent: {},
+		world.FileServerComponent:   {},
+		world.RouterComponent:       {},
+		world.SSHProxyComponent:     {},
+	},
+}
+
+func main() {
+	artifactsPath := flag.String("artifacts", "", "path to a JSON-encoded world.BuiltArtifacts file; defaults to compiling every component")
+	topologyPath := flag.String("topology", "", "path to a YAML or JSON topology file; defaults to a full single-cell cluster")
+	flag.Parse()
+
+	// the factory returns errors, but the ginkgomon runners it builds assert
+	// through gomega that their command started. Their Run recovers the
+	// resulting panic, so that the runner exits and the cluster shuts down.
+	gomega.RegisterFailHandler(func(message string, _ ...int) {
+		panic(message)
+	})
+
+	// run has torn the cluster down and removed its files when it returns
+	err := run(*artifactsPath, *topologyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "inigo-world failed: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(artifactsPath, topologyPath string) error {
+	var err error
+	topology := defaultTopology
+	if topologyPath != "" {
+		topology, err = world.LoadTopology(topologyPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	workDir, err := os.MkdirTemp("", "inigo-world")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	builtArtifacts, err := loadArtifacts(artifactsPath, workDir)
+	defer gexec.CleanupBuildArtifacts()
+	if err != nil {
+		return err
+	}
+
+	allocator, err := portauthority.NewSharedPortAllocator(portauthority.DefaultStateDir(), 10000, 32767)
+	if err != nil {
+		return err
+	}
+
+	certDepot := filepath.Join(workDir, "cert-depot")
+	err = os.Mkdir(certDepot, 0755)
+	if err != nil {
+		return err
+	}
+
+	certAuthority, err := certauthority.NewCertAuthority(certDepot, "ca")
+	if err != nil {
+		return err
+	}
+
+	addresses, err := world.NewComponentAddresses(allocator, parallelProcess)
+	if err != nil {
+		return err
+	}
+
+	factory, err := world.NewComponentFactory(builtArtifacts, addresses, allocator, certAuthority, world.FactoryOptions{
+		ParallelProcess: parallelProcess,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = factory.Setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := factory.Teardown()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tearing down: %s\n", err)
+		}
+	}()
+
+	cluster, err := world.NewCluster(factory, topology)
+	if err != nil {
+		return err
+	}
+
+	process := ifrit.Invoke(sigmon.New(cluster.Runner()))
+
+	printClusterInfo(factory, topology)
+
+	return <-process.Wait()
+}
+
+// loadArtifacts reads the artifacts at artifactsPath, or compiles them into
+// workDir if artifactsPath is empty.
+func loadArtifacts(artifactsPath, workDir string) (world.BuiltArtifacts, error) {
+	if artifactsPath == "" {
+		fmt.Println("Compiling the Diego components...")
+		buildDir := filepath.Join(workDir, "build")
+		err := os.Mkdir(buildDir, 0755)
+		if err != nil {
+			return world.BuiltArtifacts{}, err
+		}
+		return suite.BuildArtifacts(world.BuildCacheFromEnv(), buildDir, artifactsConfig, os.Stderr)
+	}
+
+	artifactsJSON, err := os.ReadFile(artifactsPath)
+	if err != nil {
+		return world.BuiltArtifacts{}, err
+	}
+
+	var builtArtifacts world.BuiltArtifacts
+	err = json.Unmarshal(artifactsJSON, &builtArtifacts)
+	if err != nil {
+		return world.BuiltArtifacts{}, fmt.Errorf("parsing %s: %w", artifactsPath, err)
+	}
+	return builtArtifacts, nil
+}
+
+func printClusterInfo(factory world.ComponentFactory, topology world.Topology) {
+	addresses := factory.Addresses()
+	bbsSSL := factory.BBSSSLConfig()
+
+	fmt.Println("Diego cluster is running. Press Ctrl-C to stop it.")
+	if _, ok := topology.Components[world.BBSComponent]; ok {
+		fmt.Printf("  BBS URL:          %s\n", factory.BBSURL())
+		fmt.Printf("  CA cert:          %s\n", bbsSSL.CACert)
+		fmt.Printf("  Client cert:      %s\n", bbsSSL.ClientCert)
+		fmt.Printf("  Client key:       %s\n", bbsSSL.ClientKey)
+	}
+	if _, ok := topology.Components[world.LocketComponent]; ok {
+		fmt.Printf("  Locket address:   %s\n", addresses.Locket)
+	}
+	if _, ok := topology.Components[world.RouterComponent]; ok {
+		fmt.Printf("  Router address:   %s\n", addresses.Router)
+	}
+	if _, ok := topology.Components[world.SSHProxyComponent]; ok {
+		fmt.Printf("  SSH proxy:        %s\n", addresses.SSHProxy)
+	}
+}
@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/world"
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+// KillAuctioneerLockHolder kills the auctioneer that holds the auctioneer
+// lock, desires task and returns the number of the killed instance and how
+// long the task stayed pending before a standby auctioneer placed it.
+// auctioneers maps the instance numbers passed to AuctioneerN to their
+// processes.
+func KillAuctioneerLockHolder(
+	logger lager.Logger,
+	componentMaker world.ComponentMaker,
+	bbsClient bbs.InternalClient,
+	auctioneers map[int]ifrit.Process,
+	task *models.Task,
+) (int, time.Duration) {
+	holder, err := componentMaker.AuctioneerLockHolder(logger)
+	Expect(err).NotTo(HaveOccurred())
+
+	process, ok := auctioneers[holder]
+	Expect(ok).To(BeTrue(), "the auctioneer lock is held by instance %d, which was not given", holder)
+
+	process.Signal(os.Kill)
+	Eventually(process.Wait()).Should(Receive())
+	killedAt := time.Now()
+
+	err = bbsClient.DesireTask(logger, "", task.TaskGuid, task.Domain, task.TaskDefinition)
+	Expect(err).NotTo(HaveOccurred())
+
+	// the lock outlives a killed holder by up to its TTL, and the BBS only
+	// retries the auction when it next converges
+	Eventually(TaskStatePoller(logger, bbsClient, task.TaskGuid, nil), 2*DEFAULT_EVENTUALLY_TIMEOUT).ShouldNot(Equal(models.Task_Pending))
+
+	return holder, time.Since(killedAt)
+}
@@ -1,9 +1,11 @@
 package certauthority_test
 
 import (
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"os"
+	"path/filepath"
 
 	"code.cloudfoundry.org/inigo/helpers/certauthority"
 	. "github.com/onsi/ginkgo/v2"
@@ -77,6 +79,86 @@ var _ = Describe("Cert Allocator", func() {
 		})
 	})
 
+	Context("when a CA is rotated", func() {
+		var (
+			oldAuthority, newAuthority certauthority.CertAuthority
+			oldDepotDir, newDepotDir   string
+		)
+
+		verifiedBy := func(bundlePath, certPath string) error {
+			bundle, err := os.ReadFile(bundlePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			roots := x509.NewCertPool()
+			Expect(roots.AppendCertsFromPEM(bundle)).To(BeTrue())
+
+			cert, _ := parseCert(certPath)
+			_, err = cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+			return err
+		}
+
+		BeforeEach(func() {
+			oldDepotDir, err = os.MkdirTemp("", "old-depot")
+			Expect(err).NotTo(HaveOccurred())
+			newDepotDir, err = os.MkdirTemp("", "new-depot")
+			Expect(err).NotTo(HaveOccurred())
+
+			oldAuthority, err = certauthority.NewCertAuthority(oldDepotDir, "old-ca")
+			Expect(err).NotTo(HaveOccurred())
+			newAuthority, err = certauthority.NewCertAuthority(newDepotDir, "new-ca")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(oldDepotDir)).To(Succeed())
+			Expect(os.RemoveAll(newDepotDir)).To(Succeed())
+		})
+
+		It("writes a bundle that trusts the certificates of every CA", func() {
+			_, oldCert, err := oldAuthority.GenerateSelfSignedCertAndKey("old-component", []string{"old-component"}, false)
+			Expect(err).NotTo(HaveOccurred())
+			_, newCert, err := newAuthority.GenerateSelfSignedCertAndKey("new-component", []string{"new-component"}, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			bundlePath := filepath.Join(oldDepotDir, "bundle.crt")
+			Expect(certauthority.WriteCABundle(bundlePath, oldAuthority, newAuthority)).To(Succeed())
+			Expect(verifiedBy(bundlePath, oldCert)).To(Succeed())
+			Expect(verifiedBy(bundlePath, newCert)).To(Succeed())
+
+			Expect(certauthority.WriteCABundle(bundlePath, newAuthority)).To(Succeed())
+			Expect(verifiedBy(bundlePath, oldCert)).NotTo(Succeed())
+			Expect(verifiedBy(bundlePath, newCert)).To(Succeed())
+		})
+
+		It("reissues a certificate in place with the new CA", func() {
+			key, cert, err := oldAuthority.GenerateSelfSignedCertAndKey("some-component", []string{"some-component"}, false)
+			Expect(err).NotTo(HaveOccurred())
+			oldKeyBytes, err := os.ReadFile(key)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = certauthority.Reissue(newAuthority, "some-component", []string{"some-component"}, key, cert)
+			Expect(err).NotTo(HaveOccurred())
+
+			newBundle := filepath.Join(newDepotDir, "bundle.crt")
+			Expect(certauthority.WriteCABundle(newBundle, newAuthority)).To(Succeed())
+			Expect(verifiedBy(newBundle, cert)).To(Succeed())
+
+			parsedCert, _ := parseCert(cert)
+			Expect(parsedCert.Subject.CommonName).To(Equal("some-component"))
+			Expect(os.ReadFile(key)).NotTo(Equal(oldKeyBytes))
+
+			_, err = tls.LoadX509KeyPair(cert, key)
+			Expect(err).NotTo(HaveOccurred())
+
+			keyInfo, err := os.Stat(key)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keyInfo.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			certInfo, err := os.Stat(cert)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(certInfo.Mode().Perm()).To(Equal(os.FileMode(0644)))
+		})
+	})
+
 	Context("when depotDir is invalid", func() {
 		BeforeEach(func() {
 			depotDir = "/random"
@@ -0,0 +1,89 @@
+package certauthority
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteCABundle writes the CA certificates of authorities to path, in order,
+// for components that are to trust any of them while a CA is rotated.
+func WriteCABundle(path string, authorities ...CertAuthority) error {
+	bundle := []byte{}
+	for _, authority := range authorities {
+		_, caCert := authority.CAAndKey()
+		caBytes, err := os.ReadFile(caCert)
+		if err != nil {
+			return err
+		}
+
+		bundle = append(bundle, caBytes...)
+		if len(caBytes) > 0 && caBytes[len(caBytes)-1] != '\n' {
+			bundle = append(bundle, '\n')
+		}
+	}
+
+	return replaceFile(path, bundle, 0644)
+}
+
+// Reissue signs a new certificate for commonName and sans with authority and
+// writes it and its key over certPath and keyPath, so that components
+// configured with those paths present it once they restart.
+func Reissue(authority CertAuthority, commonName string, sans []string, keyPath, certPath string) error {
+	newKey, newCert, err := authority.GenerateSelfSignedCertAndKey(commonName, sans, false)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(newKey)
+	defer os.Remove(newCert)
+
+	keyBytes, err := os.ReadFile(newKey)
+	if err != nil {
+		return err
+	}
+
+	certBytes, err := os.ReadFile(newCert)
+	if err != nil {
+		return err
+	}
+
+	return WriteKeyAndCert(keyPath, certPath, keyBytes, certBytes)
+}
+
+// WriteKeyAndCert writes key and cert over keyPath and certPath. The key is
+// only readable by its owner.
+func WriteKeyAndCert(keyPath, certPath string, key, cert []byte) error {
+	err := replaceFile(keyPath, key, 0600)
+	if err != nil {
+		return err
+	}
+	return replaceFile(certPath, cert, 0644)
+}
+
+// replaceFile writes data to a temporary file next to path and renames it
+// over path, so that a component starting meanwhile never reads a partly
+// written file.
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Chmod(perm)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,338 @@
+// Package chaos kills and restarts the components of a cluster at random
+// while a spec runs its workload. Every random choice is drawn from a seed,
+// so a failing run can be replayed with the same sequence of kills.
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tedsuo/ifrit"
+)
+
+const (
+	// DefaultInterval is the mean time between two kills when a Config does
+	// not set one.
+	DefaultInterval = 5 * time.Second
+
+	// restartDelay is how long a target that exited by itself, or failed to
+	// start, stays down before it is started again.
+	restartDelay = time.Second
+)
+
+// Target is a component the driver runs, kills and restarts. NewRunner is
+// called every time the target is started, since the runner of a process
+// that exited cannot be run again.
+type Target struct {
+	Name      string
+	NewRunner func() (ifrit.Runner, error)
+}
+
+// ActionKind is what happened to a target.
+type ActionKind string
+
+const (
+	// Kill is the driver killing a target.
+	Kill ActionKind = "kill"
+	// Exit is a target exiting without being killed.
+	Exit ActionKind = "exit"
+	// Restart is the driver starting a target again.
+	Restart ActionKind = "restart"
+)
+
+// Action is an event in the life of a target, Elapsed after the driver
+// started.
+type Action struct {
+	Elapsed time.Duration
+	Kind    ActionKind
+	Target  string
+	Err     error
+}
+
+func (a Action) String() string {
+	action := fmt.Sprintf("%10s %-7s %s", a.Elapsed.Round(time.Millisecond), a.Kind, a.Target)
+	if a.Err != nil {
+		action += ": " + a.Err.Error()
+	}
+	return action
+}
+
+// Config is what a Driver runs and how often it kills.
+type Config struct {
+	Seed int64
+
+	// Targets are started in order, so a target should come after the
+	// targets it depends on.
+	Targets []Target
+
+	// Interval is the mean time between two kills. Each wait is drawn
+	// between half and one and a half times the interval.
+	Interval time.Duration
+
+	// MaxDowntime bounds how long a killed target stays down before it is
+	// restarted.
+	MaxDowntime time.Duration
+}
+
+// Driver is an ifrit.Runner that starts every target of its Config and then,
+// until it is calmed or signalled, kills one target at random at random
+// intervals and restarts it after a random downtime. Targets that exit by
+// themselves are restarted as well, as monit would restart them.
+type Driver struct {
+	config Config
+	random *rand.Rand
+
+	calmOnce     sync.Once
+	calmRequests chan struct{}
+	calmed       chan struct{}
+	done         chan struct{}
+
+	mutex   sync.Mutex
+	started time.Time
+	actions []Action
+}
+
+// New returns a Driver for config.
+func New(config Config) *Driver {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+
+	return &Driver{
+		config:       config,
+		random:       rand.New(rand.NewSource(config.Seed)),
+		calmRequests: make(chan struct{}),
+		calmed:       make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Seed is the seed the driver's choices are drawn from.
+func (d *Driver) Seed() int64 {
+	return d.config.Seed
+}
+
+// member is a target and its current process, which is nil while the target
+// is down. generation tells the exits of earlier processes apart.
+type member struct {
+	target     Target
+	process    ifrit.Process
+	generation int
+}
+
+type exit struct {
+	member     *member
+	generation int
+	err        error
+}
+
+func (d *Driver) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	defer close(d.done)
+
+	d.mutex.Lock()
+	d.started = time.Now()
+	d.mutex.Unlock()
+
+	exits := make(chan exit)
+	members := []*member{}
+	for _, target := range d.config.Targets {
+		m := &member{target: target}
+		err := d.start(m, exits)
+		if err != nil {
+			d.stop(members)
+			return fmt.Errorf("starting %s: %w", target.Name, err)
+		}
+		members = append(members, m)
+	}
+
+	close(ready)
+
+	kills := time.NewTimer(d.nextKill())
+	defer kills.Stop()
+
+	calmRequests := d.calmRequests
+	for {
+		select {
+		case <-signals:
+			d.stop(members)
+			return nil
+
+		case <-calmRequests:
+			calmRequests = nil
+			kills.Stop()
+			close(d.calmed)
+
+		case e := <-exits:
+			if e.generation != e.member.generation {
+				continue
+			}
+
+			d.record(Exit, e.member.target.Name, e.err)
+			e.member.process = nil
+			if !d.restart(e.member, restartDelay, exits, signals) {
+				d.stop(members)
+				return nil
+			}
+
+		case <-kills.C:
+			m := d.pick(members)
+			downtime := d.downtime()
+			if m != nil {
+				d.kill(m)
+				if !d.restart(m, downtime, exits, signals) {
+					d.stop(members)
+					return nil
+				}
+			}
+			kills.Reset(d.nextKill())
+		}
+	}
+}
+
+// Calm stops the kills and returns once every target is running. Targets
+// that exit afterwards are still restarted.
+func (d *Driver) Calm() {
+	d.calmOnce.Do(func() { close(d.calmRequests) })
+
+	select {
+	case <-d.calmed:
+	case <-d.done:
+	}
+}
+
+// Actions returns every action taken so far, in order.
+func (d *Driver) Actions() []Action {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]Action{}, d.actions...)
+}
+
+// Report describes the seed and every action taken so far, one per line, for
+// the report of a spec that failed under chaos.
+func (d *Driver) Report() string {
+	report := &strings.Builder{}
+	fmt.Fprintf(report, "chaos seed %d\n", d.config.Seed)
+	for _, action := range d.Actions() {
+		fmt.Fprintln(report, action)
+	}
+	return report.String()
+}
+
+func (d *Driver) record(kind ActionKind, target string, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.actions = append(d.actions, Action{
+		Elapsed: time.Since(d.started),
+		Kind:    kind,
+		Target:  target,
+		Err:     err,
+	})
+}
+
+// start runs a new process for m and waits for it to be ready.
+func (d *Driver) start(m *member, exits chan<- exit) error {
+	runner, err := m.target.NewRunner()
+	if err != nil {
+		return err
+	}
+
+	process := ifrit.Background(runner)
+	select {
+	case <-process.Ready():
+	case err := <-process.Wait():
+		if err == nil {
+			err = fmt.Errorf("exited before it was ready")
+		}
+		return err
+	}
+
+	m.generation++
+	m.process = process
+
+	go func(generation int) {
+		err := <-process.Wait()
+		select {
+		case exits <- exit{member: m, generation: generation, err: err}:
+		case <-d.done:
+		}
+	}(m.generation)
+
+	return nil
+}
+
+// restart starts m again after downtime, retrying until it starts. It
+// returns false if the driver was signalled first.
+func (d *Driver) restart(m *member, downtime time.Duration, exits chan<- exit, signals <-chan os.Signal) bool {
+	for {
+		select {
+		case <-time.After(downtime):
+		case <-signals:
+			return false
+		}
+
+		err := d.start(m, exits)
+		d.record(Restart, m.target.Name, err)
+		if err == nil {
+			return true
+		}
+		downtime = restartDelay
+	}
+}
+
+// kill kills the process of m and waits for it to exit. Its exit status is
+// what the kill asked for, so it is neither reported as an Exit nor recorded.
+func (d *Driver) kill(m *member) {
+	process := m.process
+	m.generation++
+	m.process = nil
+
+	process.Signal(os.Kill)
+	<-process.Wait()
+	d.record(Kill, m.target.Name, nil)
+}
+
+// stop interrupts the running members in the reverse of the order they were
+// started, waiting for each to exit.
+func (d *Driver) stop(members []*member) {
+	for i := len(members) - 1; i >= 0; i-- {
+		m := members[i]
+		if m.process == nil {
+			continue
+		}
+
+		m.generation++
+		m.process.Signal(os.Interrupt)
+		<-m.process.Wait()
+		m.process = nil
+	}
+}
+
+// pick returns a running member at random, or nil if none is running.
+func (d *Driver) pick(members []*member) *member {
+	running := []*member{}
+	for _, m := range members {
+		if m.process != nil {
+			running = append(running, m)
+		}
+	}
+	if len(running) == 0 {
+		return nil
+	}
+	return running[d.random.Intn(len(running))]
+}
+
+func (d *Driver) nextKill() time.Duration {
+	return d.config.Interval/2 + time.Duration(d.random.Int63n(int64(d.config.Interval)))
+}
+
+func (d *Driver) downtime() time.Duration {
+	if d.config.MaxDowntime <= 0 {
+		return 0
+	}
+	return time.Duration(d.random.Int63n(int64(d.config.MaxDowntime)))
+}
@@ -0,0 +1,230 @@
+package chaos_test
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/chaos"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+// component counts the runs of a fake component and lets specs make its
+// current run exit.
+type component struct {
+	name string
+
+	mutex   sync.Mutex
+	starts  int
+	running bool
+	exit    chan error
+}
+
+func newComponent(name string) *component {
+	return &component{name: name}
+}
+
+func (c *component) target() chaos.Target {
+	return chaos.Target{
+		Name: c.name,
+		NewRunner: func() (ifrit.Runner, error) {
+			return ifrit.RunFunc(c.run), nil
+		},
+	}
+}
+
+func (c *component) run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	exit := make(chan error, 1)
+
+	c.mutex.Lock()
+	c.starts++
+	c.running = true
+	c.exit = exit
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		c.running = false
+		c.mutex.Unlock()
+	}()
+
+	close(ready)
+	select {
+	case <-signals:
+		return nil
+	case err := <-exit:
+		return err
+	}
+}
+
+func (c *component) Starts() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.starts
+}
+
+func (c *component) Running() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.running
+}
+
+func (c *component) Exit(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.exit <- err
+}
+
+var _ = Describe("Driver", func() {
+	var (
+		components []*component
+		driver     *chaos.Driver
+		process    ifrit.Process
+	)
+
+	newDriver := func(seed int64, interval time.Duration) *chaos.Driver {
+		targets := []chaos.Target{}
+		for _, c := range components {
+			targets = append(targets, c.target())
+		}
+		return chaos.New(chaos.Config{
+			Seed:        seed,
+			Targets:     targets,
+			Interval:    interval,
+			MaxDowntime: 10 * time.Millisecond,
+		})
+	}
+
+	killedTargets := func(driver *chaos.Driver) []string {
+		targets := []string{}
+		for _, action := range driver.Actions() {
+			if action.Kind == chaos.Kill {
+				targets = append(targets, action.Target)
+			}
+		}
+		return targets
+	}
+
+	BeforeEach(func() {
+		components = []*component{newComponent("locket"), newComponent("bbs"), newComponent("rep")}
+	})
+
+	AfterEach(func() {
+		if process != nil {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		}
+	})
+
+	It("starts every target before it is ready", func() {
+		driver = newDriver(1, time.Hour)
+		process = ifrit.Invoke(driver)
+
+		for _, c := range components {
+			Expect(c.Running()).To(BeTrue(), c.name)
+			Expect(c.Starts()).To(Equal(1), c.name)
+		}
+	})
+
+	It("fails to start when a target fails to start", func() {
+		driver = chaos.New(chaos.Config{Targets: []chaos.Target{
+			components[0].target(),
+			{Name: "broken", NewRunner: func() (ifrit.Runner, error) { return nil, errors.New("no config") }},
+		}})
+		process = ifrit.Invoke(driver)
+
+		var err error
+		Eventually(process.Wait()).Should(Receive(&err))
+		Expect(err).To(MatchError(ContainSubstring("starting broken: no config")))
+		Expect(components[0].Running()).To(BeFalse())
+		process = nil
+	})
+
+	It("kills and restarts targets", func() {
+		driver = newDriver(1, 20*time.Millisecond)
+		process = ifrit.Invoke(driver)
+
+		Eventually(func() int { return len(killedTargets(driver)) }).Should(BeNumerically(">=", 5))
+
+		driver.Calm()
+		for _, c := range components {
+			Expect(c.Running()).To(BeTrue(), c.name)
+		}
+
+		restarts := 0
+		for _, c := range components {
+			restarts += c.Starts() - 1
+		}
+		Expect(restarts).To(Equal(len(killedTargets(driver))))
+	})
+
+	It("kills the same targets in the same order for the same seed", func() {
+		first := newDriver(42, 10*time.Millisecond)
+		firstProcess := ifrit.Invoke(first)
+		Eventually(func() int { return len(killedTargets(first)) }).Should(BeNumerically(">=", 8))
+		first.Calm()
+		firstProcess.Signal(os.Interrupt)
+		Eventually(firstProcess.Wait()).Should(Receive())
+
+		second := newDriver(42, 10*time.Millisecond)
+		process = ifrit.Invoke(second)
+		Eventually(func() int { return len(killedTargets(second)) }).Should(BeNumerically(">=", 8))
+		second.Calm()
+
+		Expect(killedTargets(second)[:8]).To(Equal(killedTargets(first)[:8]))
+	})
+
+	It("stops killing once it is calmed", func() {
+		driver = newDriver(1, 10*time.Millisecond)
+		process = ifrit.Invoke(driver)
+
+		driver.Calm()
+		kills := len(killedTargets(driver))
+		Consistently(func() int { return len(killedTargets(driver)) }, 100*time.Millisecond).Should(Equal(kills))
+	})
+
+	It("restarts targets that exit by themselves", func() {
+		driver = newDriver(1, time.Hour)
+		process = ifrit.Invoke(driver)
+
+		components[1].Exit(errors.New("lost the lock"))
+
+		Eventually(components[1].Starts, 5*time.Second).Should(Equal(2))
+		Eventually(components[1].Running).Should(BeTrue())
+		Eventually(driver.Actions).Should(ContainElement(SatisfyAll(
+			HaveField("Kind", chaos.Exit),
+			HaveField("Target", "bbs"),
+			HaveField("Err", MatchError("lost the lock")),
+		)))
+	})
+
+	It("stops every target when it is signalled", func() {
+		driver = newDriver(1, time.Hour)
+		process = ifrit.Invoke(driver)
+
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		process = nil
+
+		for _, c := range components {
+			Expect(c.Running()).To(BeFalse(), c.name)
+		}
+	})
+
+	It("reports the seed and every action", func() {
+		driver = newDriver(7, 10*time.Millisecond)
+		process = ifrit.Invoke(driver)
+
+		Eventually(func() int { return len(killedTargets(driver)) }).Should(BeNumerically(">=", 1))
+		driver.Calm()
+
+		report := driver.Report()
+		Expect(report).To(HavePrefix("chaos seed 7\n"))
+		for _, action := range driver.Actions() {
+			Expect(report).To(ContainSubstring(action.String()))
+		}
+	})
+})
@@ -0,0 +1 @@
+package chaos // import "code.cloudfoundry.org/inigo/helpers/chaos"
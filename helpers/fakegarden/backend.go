@@ -0,0 +1,218 @@
+package fakegarden
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+const (
+	// firstHostPort is the first port handed out for NetIn mappings. Nothing
+	// listens on mapped ports.
+	firstHostPort = 61000
+
+	gibibyte = 1024 * 1024 * 1024
+)
+
+// backend is the garden.Backend behind a Garden.
+type backend struct {
+	mutex sync.Mutex
+
+	containers    map[string]*container
+	nextID        int
+	nextHostPort  uint32
+	capacity      garden.Capacity
+	processScript ProcessScript
+
+	createDelay  time.Duration
+	createErr    error
+	destroyDelay time.Duration
+	destroyErr   error
+}
+
+func newBackend() *backend {
+	return &backend{
+		containers:   map[string]*container{},
+		nextHostPort: firstHostPort,
+		capacity: garden.Capacity{
+			MemoryInBytes:          8 * gibibyte,
+			DiskInBytes:            16 * gibibyte,
+			SchedulableDiskInBytes: 16 * gibibyte,
+			MaxContainers:          256,
+		},
+		processScript: DefaultProcessScript,
+	}
+}
+
+func (b *backend) Start() error {
+	return nil
+}
+
+func (b *backend) Stop() error {
+	b.mutex.Lock()
+	containers := make([]*container, 0, len(b.containers))
+	for _, c := range b.containers {
+		containers = append(containers, c)
+	}
+	b.mutex.Unlock()
+
+	for _, c := range containers {
+		c.Stop(true)
+	}
+	return nil
+}
+
+// GraceTime is zero, so that containers are never reaped.
+func (b *backend) GraceTime(garden.Container) time.Duration {
+	return 0
+}
+
+func (b *backend) Ping() error {
+	return nil
+}
+
+func (b *backend) Capacity() (garden.Capacity, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.capacity, nil
+}
+
+func (b *backend) Create(spec garden.ContainerSpec) (garden.Container, error) {
+	b.mutex.Lock()
+	delay, err := b.createDelay, b.createErr
+	b.mutex.Unlock()
+
+	time.Sleep(delay)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.nextID++
+	handle := spec.Handle
+	if handle == "" {
+		handle = fmt.Sprintf("fake-container-%d", b.nextID)
+	}
+	if _, exists := b.containers[handle]; exists {
+		return nil, fmt.Errorf("handle already exists: %s", handle)
+	}
+
+	c := newContainer(b, handle, b.nextID, spec)
+	for _, netIn := range spec.NetIn {
+		c.mapPort(b.hostPort(netIn.HostPort), netIn.ContainerPort)
+	}
+
+	b.containers[handle] = c
+	return c, nil
+}
+
+// hostPort returns requested, or the next free host port if it is zero. It
+// is called with the mutex held.
+func (b *backend) hostPort(requested uint32) uint32 {
+	if requested != 0 {
+		return requested
+	}
+	b.nextHostPort++
+	return b.nextHostPort - 1
+}
+
+func (b *backend) Destroy(handle string) error {
+	b.mutex.Lock()
+	delay, err := b.destroyDelay, b.destroyErr
+	b.mutex.Unlock()
+
+	time.Sleep(delay)
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	c, ok := b.containers[handle]
+	delete(b.containers, handle)
+	b.mutex.Unlock()
+
+	if !ok {
+		return garden.ContainerNotFoundError{Handle: handle}
+	}
+	return c.Stop(true)
+}
+
+func (b *backend) Containers(properties garden.Properties) ([]garden.Container, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	containers := []garden.Container{}
+	for _, handle := range b.sortedHandles() {
+		c := b.containers[handle]
+		if c.hasProperties(properties) {
+			containers = append(containers, c)
+		}
+	}
+	return containers, nil
+}
+
+func (b *backend) BulkInfo(handles []string) (map[string]garden.ContainerInfoEntry, error) {
+	infos := map[string]garden.ContainerInfoEntry{}
+	for _, handle := range handles {
+		c, err := b.Lookup(handle)
+		if err != nil {
+			infos[handle] = garden.ContainerInfoEntry{Err: garden.NewError(err.Error())}
+			continue
+		}
+
+		info, _ := c.Info()
+		infos[handle] = garden.ContainerInfoEntry{Info: info}
+	}
+	return infos, nil
+}
+
+func (b *backend) BulkMetrics(handles []string) (map[string]garden.ContainerMetricsEntry, error) {
+	metrics := map[string]garden.ContainerMetricsEntry{}
+	for _, handle := range handles {
+		_, err := b.Lookup(handle)
+		if err != nil {
+			metrics[handle] = garden.ContainerMetricsEntry{Err: garden.NewError(err.Error())}
+			continue
+		}
+		metrics[handle] = garden.ContainerMetricsEntry{}
+	}
+	return metrics, nil
+}
+
+func (b *backend) Lookup(handle string) (garden.Container, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c, ok := b.containers[handle]
+	if !ok {
+		return nil, garden.ContainerNotFoundError{Handle: handle}
+	}
+	return c, nil
+}
+
+func (b *backend) script() ProcessScript {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.processScript
+}
+
+func (b *backend) handles() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.sortedHandles()
+}
+
+// sortedHandles is called with the mutex held.
+func (b *backend) sortedHandles() []string {
+	handles := make([]string, 0, len(b.containers))
+	for handle := range b.containers {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+	return handles
+}
@@ -0,0 +1,278 @@
+package fakegarden
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// container is a simulated container. Its filesystem is not kept: files
+// streamed in are discarded, and files streamed out are empty.
+type container struct {
+	backend *backend
+	handle  string
+	ip      string
+
+	mutex       sync.Mutex
+	stopped     bool
+	properties  garden.Properties
+	limits      garden.Limits
+	mappedPorts []garden.PortMapping
+	processes   map[string]*process
+	nextProcess int
+}
+
+func newContainer(b *backend, handle string, id int, spec garden.ContainerSpec) *container {
+	properties := garden.Properties{}
+	for name, value := range spec.Properties {
+		properties[name] = value
+	}
+
+	return &container{
+		backend:    b,
+		handle:     handle,
+		ip:         fmt.Sprintf("10.255.%d.%d", (id/254)%256, id%254+1),
+		properties: properties,
+		limits:     spec.Limits,
+		processes:  map[string]*process{},
+	}
+}
+
+func (c *container) Handle() string {
+	return c.handle
+}
+
+func (c *container) Stop(kill bool) error {
+	c.mutex.Lock()
+	c.stopped = true
+	processes := make([]*process, 0, len(c.processes))
+	for _, p := range c.processes {
+		processes = append(processes, p)
+	}
+	c.mutex.Unlock()
+
+	signal := garden.SignalTerminate
+	if kill {
+		signal = garden.SignalKill
+	}
+	for _, p := range processes {
+		p.Signal(signal)
+	}
+	return nil
+}
+
+func (c *container) Info() (garden.ContainerInfo, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := "active"
+	if c.stopped {
+		state = "stopped"
+	}
+
+	processIDs := []string{}
+	for id, p := range c.processes {
+		if !p.exited() {
+			processIDs = append(processIDs, id)
+		}
+	}
+
+	properties := garden.Properties{}
+	for name, value := range c.properties {
+		properties[name] = value
+	}
+
+	return garden.ContainerInfo{
+		State:         state,
+		HostIP:        "127.0.0.1",
+		ContainerIP:   c.ip,
+		ExternalIP:    "127.0.0.1",
+		ContainerPath: path.Join("/fake-garden/containers", c.handle),
+		ProcessIDs:    processIDs,
+		Properties:    properties,
+		MappedPorts:   append([]garden.PortMapping{}, c.mappedPorts...),
+	}, nil
+}
+
+func (c *container) StreamIn(spec garden.StreamInSpec) error {
+	_, err := io.Copy(io.Discard, spec.TarStream)
+	return err
+}
+
+// StreamOut returns a tar stream holding an empty file named after the
+// requested path.
+func (c *container) StreamOut(spec garden.StreamOutSpec) (io.ReadCloser, error) {
+	var stream bytes.Buffer
+	writer := tar.NewWriter(&stream)
+
+	err := writer.WriteHeader(&tar.Header{
+		Name:    path.Base(spec.Path),
+		Mode:    0644,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&stream), nil
+}
+
+func (c *container) CurrentBandwidthLimits() (garden.BandwidthLimits, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.limits.Bandwidth, nil
+}
+
+func (c *container) CurrentCPULimits() (garden.CPULimits, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.limits.CPU, nil
+}
+
+func (c *container) CurrentDiskLimits() (garden.DiskLimits, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.limits.Disk, nil
+}
+
+func (c *container) CurrentMemoryLimits() (garden.MemoryLimits, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.limits.Memory, nil
+}
+
+func (c *container) NetIn(hostPort, containerPort uint32) (uint32, uint32, error) {
+	c.backend.mutex.Lock()
+	hostPort = c.backend.hostPort(hostPort)
+	c.backend.mutex.Unlock()
+
+	if containerPort == 0 {
+		containerPort = hostPort
+	}
+
+	c.mapPort(hostPort, containerPort)
+	return hostPort, containerPort, nil
+}
+
+func (c *container) mapPort(hostPort, containerPort uint32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.mappedPorts = append(c.mappedPorts, garden.PortMapping{HostPort: hostPort, ContainerPort: containerPort})
+}
+
+func (c *container) NetOut(garden.NetOutRule) error {
+	return nil
+}
+
+func (c *container) BulkNetOut([]garden.NetOutRule) error {
+	return nil
+}
+
+func (c *container) Run(spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+	behavior := c.backend.script()(c.handle, spec)
+
+	c.mutex.Lock()
+	if c.stopped {
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("container %s is stopped", c.handle)
+	}
+
+	c.nextProcess++
+	id := spec.ID
+	if id == "" {
+		id = fmt.Sprintf("%s-process-%d", c.handle, c.nextProcess)
+	}
+	if _, exists := c.processes[id]; exists {
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("process %s already exists", id)
+	}
+
+	p := newProcess(id, behavior)
+	c.processes[id] = p
+	c.mutex.Unlock()
+
+	p.start(processIO)
+	return p, nil
+}
+
+func (c *container) Attach(processID string, processIO garden.ProcessIO) (garden.Process, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	p, ok := c.processes[processID]
+	if !ok {
+		return nil, garden.ProcessNotFoundError{ProcessID: processID}
+	}
+	return p, nil
+}
+
+func (c *container) Metrics() (garden.Metrics, error) {
+	return garden.Metrics{}, nil
+}
+
+func (c *container) SetGraceTime(time.Duration) error {
+	return nil
+}
+
+func (c *container) Properties() (garden.Properties, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	properties := garden.Properties{}
+	for name, value := range c.properties {
+		properties[name] = value
+	}
+	return properties, nil
+}
+
+func (c *container) Property(name string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	value, ok := c.properties[name]
+	if !ok {
+		return "", fmt.Errorf("property does not exist: %s", name)
+	}
+	return value, nil
+}
+
+func (c *container) SetProperty(name string, value string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.properties[name] = value
+	return nil
+}
+
+func (c *container) RemoveProperty(name string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.properties[name]; !ok {
+		return fmt.Errorf("property does not exist: %s", name)
+	}
+	delete(c.properties, name)
+	return nil
+}
+
+// hasProperties returns whether the container has every one of properties.
+func (c *container) hasProperties(properties garden.Properties) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for name, value := range properties {
+		if c.properties[name] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+package fakegarden_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFakegarden(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fakegarden Suite")
+}
@@ -0,0 +1,256 @@
+package fakegarden_test
+
+import (
+	"archive/tar"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/client"
+	"code.cloudfoundry.org/garden/client/connection"
+	"code.cloudfoundry.org/inigo/helpers/fakegarden"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Garden", func() {
+	var (
+		gardenServer *fakegarden.Garden
+		process      ifrit.Process
+		gardenClient garden.Client
+	)
+
+	BeforeEach(func() {
+		gardenServer = fakegarden.New(freeAddress(), lagertest.NewTestLogger("fake-garden"))
+		process = ifrit.Invoke(gardenServer)
+		gardenClient = client.New(connection.New("tcp", gardenServer.Address()))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("serves the Garden API", func() {
+		Expect(gardenClient.Ping()).To(Succeed())
+
+		capacity, err := gardenClient.Capacity()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capacity.MaxContainers).To(BeNumerically(">", 0))
+	})
+
+	Describe("containers", func() {
+		It("creates, lists, looks up and destroys them", func() {
+			_, err := gardenClient.Create(garden.ContainerSpec{Handle: "first", Properties: garden.Properties{"owner": "inigo"}})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = gardenClient.Create(garden.ContainerSpec{Handle: "second"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(gardenServer.Handles()).To(Equal([]string{"first", "second"}))
+
+			containers, err := gardenClient.Containers(garden.Properties{"owner": "inigo"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containers).To(HaveLen(1))
+			Expect(containers[0].Handle()).To(Equal("first"))
+
+			container, err := gardenClient.Lookup("second")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.Handle()).To(Equal("second"))
+
+			Expect(gardenClient.Destroy("first")).To(Succeed())
+			Expect(gardenServer.Handles()).To(Equal([]string{"second"}))
+
+			_, err = gardenClient.Lookup("first")
+			Expect(err).To(BeAssignableToTypeOf(garden.ContainerNotFoundError{}))
+			Expect(gardenClient.Destroy("first")).To(BeAssignableToTypeOf(garden.ContainerNotFoundError{}))
+		})
+
+		It("generates handles", func() {
+			container, err := gardenClient.Create(garden.ContainerSpec{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.Handle()).NotTo(BeEmpty())
+			Expect(gardenServer.Handles()).To(ConsistOf(container.Handle()))
+		})
+
+		It("maps ports and reports them in the container's info", func() {
+			container, err := gardenClient.Create(garden.ContainerSpec{
+				Handle: "ports",
+				NetIn:  []garden.NetIn{{ContainerPort: 8080}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			hostPort, containerPort, err := container.NetIn(0, 2222)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containerPort).To(BeEquivalentTo(2222))
+
+			info, err := container.Info()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.State).To(Equal("active"))
+			Expect(info.ExternalIP).To(Equal("127.0.0.1"))
+			Expect(info.ContainerIP).NotTo(BeEmpty())
+			Expect(info.MappedPorts).To(HaveLen(2))
+			Expect(info.MappedPorts[0].ContainerPort).To(BeEquivalentTo(8080))
+			Expect(info.MappedPorts[0].HostPort).NotTo(Equal(hostPort))
+			Expect(info.MappedPorts[1]).To(Equal(garden.PortMapping{HostPort: hostPort, ContainerPort: 2222}))
+		})
+
+		It("keeps properties", func() {
+			container, err := gardenClient.Create(garden.ContainerSpec{Properties: garden.Properties{"a": "1"}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(container.SetProperty("b", "2")).To(Succeed())
+			Expect(container.RemoveProperty("a")).To(Succeed())
+
+			properties, err := container.Properties()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(properties).To(Equal(garden.Properties{"b": "2"}))
+
+			_, err = container.Property("a")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("streams files in and out", func() {
+			container, err := gardenClient.Create(garden.ContainerSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			stream, err := container.StreamOut(garden.StreamOutSpec{Path: "/tmp/result.json"})
+			Expect(err).NotTo(HaveOccurred())
+			defer stream.Close()
+
+			header, err := tar.NewReader(stream).Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(header.Name).To(Equal("result.json"))
+		})
+	})
+
+	Describe("processes", func() {
+		var container garden.Container
+
+		BeforeEach(func() {
+			var err error
+			container, err = gardenClient.Create(garden.ContainerSpec{Handle: "processes"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("exits them immediately by default", func() {
+			process, err := container.Run(garden.ProcessSpec{Path: "/bin/true"}, garden.ProcessIO{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(process.Wait()).To(Equal(0))
+		})
+
+		It("runs shell loops until they are signalled", func() {
+			process, err := container.Run(garden.ProcessSpec{
+				Path: "sh",
+				Args: []string{"-c", "while true; do sleep 1; done"},
+			}, garden.ProcessIO{})
+			Expect(err).NotTo(HaveOccurred())
+
+			exited := make(chan int)
+			go func() {
+				defer GinkgoRecover()
+				status, err := process.Wait()
+				Expect(err).NotTo(HaveOccurred())
+				exited <- status
+			}()
+			Consistently(exited, 200*time.Millisecond).ShouldNot(Receive())
+
+			info, err := container.Info()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.ProcessIDs).To(ConsistOf(process.ID()))
+
+			Expect(process.Signal(garden.SignalKill)).To(Succeed())
+			Eventually(exited).Should(Receive(Equal(137)))
+		})
+
+		It("kills them when the container is destroyed", func() {
+			gardenServer.SetProcessScript(func(string, garden.ProcessSpec) fakegarden.ProcessBehavior {
+				return fakegarden.RunUntilSignalled
+			})
+
+			process, err := container.Run(garden.ProcessSpec{Path: "/bin/sleep"}, garden.ProcessIO{})
+			Expect(err).NotTo(HaveOccurred())
+
+			exited := make(chan int)
+			go func() {
+				defer GinkgoRecover()
+				status, _ := process.Wait()
+				exited <- status
+			}()
+
+			Expect(gardenClient.Destroy("processes")).To(Succeed())
+			Eventually(exited).Should(Receive())
+		})
+
+		It("follows the process script", func() {
+			var scriptedHandle string
+			gardenServer.SetProcessScript(func(handle string, spec garden.ProcessSpec) fakegarden.ProcessBehavior {
+				scriptedHandle = handle
+				return fakegarden.ProcessBehavior{
+					RunFor:     100 * time.Millisecond,
+					ExitStatus: 3,
+					Stdout:     "hello from " + spec.Path + "\n",
+				}
+			})
+
+			stdout := gbytes.NewBuffer()
+			process, err := container.Run(garden.ProcessSpec{Path: "/bin/greet"}, garden.ProcessIO{Stdout: stdout})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(process.Wait()).To(Equal(3))
+			Eventually(stdout).Should(gbytes.Say("hello from /bin/greet"))
+			Expect(scriptedHandle).To(Equal("processes"))
+		})
+	})
+
+	Describe("scripted failures", func() {
+		It("fails and delays creation", func() {
+			gardenServer.FailCreate(errors.New("out of space"))
+			_, err := gardenClient.Create(garden.ContainerSpec{})
+			Expect(err).To(MatchError(ContainSubstring("out of space")))
+			Expect(gardenServer.Handles()).To(BeEmpty())
+
+			gardenServer.FailCreate(nil)
+			gardenServer.DelayCreate(300 * time.Millisecond)
+			started := time.Now()
+			_, err = gardenClient.Create(garden.ContainerSpec{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Since(started)).To(BeNumerically(">=", 300*time.Millisecond))
+		})
+
+		It("fails and delays destruction, keeping the container", func() {
+			_, err := gardenClient.Create(garden.ContainerSpec{Handle: "stuck"})
+			Expect(err).NotTo(HaveOccurred())
+
+			gardenServer.FailDestroy(errors.New("device busy"))
+			Expect(gardenClient.Destroy("stuck")).To(MatchError(ContainSubstring("device busy")))
+			Expect(gardenServer.Handles()).To(ConsistOf("stuck"))
+
+			gardenServer.FailDestroy(nil)
+			gardenServer.DelayDestroy(300 * time.Millisecond)
+			started := time.Now()
+			Expect(gardenClient.Destroy("stuck")).To(Succeed())
+			Expect(time.Since(started)).To(BeNumerically(">=", 300*time.Millisecond))
+			Expect(gardenServer.Handles()).To(BeEmpty())
+		})
+
+		It("reports the scripted capacity", func() {
+			gardenServer.SetCapacity(garden.Capacity{MemoryInBytes: 1024, DiskInBytes: 2048, MaxContainers: 1})
+
+			capacity, err := gardenClient.Capacity()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(capacity).To(Equal(garden.Capacity{MemoryInBytes: 1024, DiskInBytes: 2048, MaxContainers: 1}))
+		})
+	})
+})
+
+func freeAddress() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer listener.Close()
+	return listener.Addr().String()
+}
@@ -0,0 +1,131 @@
+// Package fakegarden serves the Garden API from an in-process backend that
+// simulates containers, processes, port mappings and properties, so that the
+// rep can schedule work without runc, grootfs or root privileges. Nothing a
+// process is asked to run is executed; how each process behaves is decided
+// by a ProcessScript.
+package fakegarden
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/server"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// ProcessBehavior is how a simulated process runs.
+type ProcessBehavior struct {
+	// RunFor is how long the process runs before it exits with ExitStatus.
+	// A negative duration runs it until it is signalled.
+	RunFor     time.Duration
+	ExitStatus int
+
+	// Stdout is written to the process's stdout when it starts.
+	Stdout string
+}
+
+// RunUntilSignalled is the behavior of a process that never exits by itself.
+var RunUntilSignalled = ProcessBehavior{RunFor: -1}
+
+// ProcessScript decides how a process run in the container with handle
+// behaves.
+type ProcessScript func(handle string, spec garden.ProcessSpec) ProcessBehavior
+
+// DefaultProcessScript exits every process immediately with status 0, which
+// completes tasks and passes health checks, except for shell loops such as
+// the action of helpers.LightweightLRPCreateRequest, which run until they are
+// signalled.
+func DefaultProcessScript(handle string, spec garden.ProcessSpec) ProcessBehavior {
+	commandLine := spec.Path + " " + strings.Join(spec.Args, " ")
+	if strings.Contains(commandLine, "while true") {
+		return RunUntilSignalled
+	}
+	return ProcessBehavior{}
+}
+
+// Garden is an ifrit.Runner serving the Garden API on a TCP address. Its
+// methods script the backend while it runs.
+type Garden struct {
+	listenAddress string
+	logger        lager.Logger
+	backend       *backend
+}
+
+// New returns a Garden that listens on listenAddress once it is run.
+func New(listenAddress string, logger lager.Logger) *Garden {
+	return &Garden{
+		listenAddress: listenAddress,
+		logger:        logger,
+		backend:       newBackend(),
+	}
+}
+
+// Address is where the Garden API is served.
+func (g *Garden) Address() string {
+	return g.listenAddress
+}
+
+func (g *Garden) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	gardenServer := server.New("tcp", g.listenAddress, 0, g.backend, g.logger)
+
+	err := gardenServer.Start()
+	if err != nil {
+		return err
+	}
+
+	close(ready)
+	<-signals
+
+	return gardenServer.Stop()
+}
+
+// SetCapacity sets the capacity reported to the rep.
+func (g *Garden) SetCapacity(capacity garden.Capacity) {
+	g.backend.mutex.Lock()
+	defer g.backend.mutex.Unlock()
+	g.backend.capacity = capacity
+}
+
+// SetProcessScript decides how every process run from now on behaves.
+func (g *Garden) SetProcessScript(script ProcessScript) {
+	g.backend.mutex.Lock()
+	defer g.backend.mutex.Unlock()
+	g.backend.processScript = script
+}
+
+// DelayCreate makes every container creation take at least delay.
+func (g *Garden) DelayCreate(delay time.Duration) {
+	g.backend.mutex.Lock()
+	defer g.backend.mutex.Unlock()
+	g.backend.createDelay = delay
+}
+
+// FailCreate fails every container creation with err, until it is called
+// with nil.
+func (g *Garden) FailCreate(err error) {
+	g.backend.mutex.Lock()
+	defer g.backend.mutex.Unlock()
+	g.backend.createErr = err
+}
+
+// DelayDestroy makes every container destruction take at least delay.
+func (g *Garden) DelayDestroy(delay time.Duration) {
+	g.backend.mutex.Lock()
+	defer g.backend.mutex.Unlock()
+	g.backend.destroyDelay = delay
+}
+
+// FailDestroy fails every container destruction with err, until it is called
+// with nil. Containers that fail to be destroyed are kept.
+func (g *Garden) FailDestroy(err error) {
+	g.backend.mutex.Lock()
+	defer g.backend.mutex.Unlock()
+	g.backend.destroyErr = err
+}
+
+// Handles returns the handles of the existing containers.
+func (g *Garden) Handles() []string {
+	return g.backend.handles()
+}
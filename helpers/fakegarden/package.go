@@ -0,0 +1 @@
+package fakegarden // import "code.cloudfoundry.org/inigo/helpers/fakegarden"
@@ -0,0 +1,87 @@
+package fakegarden
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// The exit statuses of processes ended by a signal, as a shell reports them.
+const (
+	terminatedExitStatus = 128 + 15
+	killedExitStatus     = 128 + 9
+)
+
+// process is a simulated process that runs as its ProcessBehavior says.
+type process struct {
+	id       string
+	behavior ProcessBehavior
+
+	once       sync.Once
+	done       chan struct{}
+	exitStatus int
+}
+
+func newProcess(id string, behavior ProcessBehavior) *process {
+	return &process{
+		id:       id,
+		behavior: behavior,
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *process) start(processIO garden.ProcessIO) {
+	if p.behavior.Stdout != "" && processIO.Stdout != nil {
+		// #nosec G104 - the process does not care whether anyone is reading
+		io.WriteString(processIO.Stdout, p.behavior.Stdout)
+	}
+
+	if p.behavior.RunFor < 0 {
+		return
+	}
+
+	go func() {
+		time.Sleep(p.behavior.RunFor)
+		p.exit(p.behavior.ExitStatus)
+	}()
+}
+
+func (p *process) exit(status int) {
+	p.once.Do(func() {
+		p.exitStatus = status
+		close(p.done)
+	})
+}
+
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *process) ID() string {
+	return p.id
+}
+
+func (p *process) Wait() (int, error) {
+	<-p.done
+	return p.exitStatus, nil
+}
+
+func (p *process) SetTTY(garden.TTYSpec) error {
+	return nil
+}
+
+func (p *process) Signal(signal garden.Signal) error {
+	if signal == garden.SignalKill {
+		p.exit(killedExitStatus)
+	} else {
+		p.exit(terminatedExitStatus)
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+// Package faultproxy is a TCP proxy that injects network faults between a
+// component and what it connects to. Faults are changed while the proxy
+// runs, and apply to the connections already open as well as to new ones.
+package faultproxy
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// bufferSize is the most a connection forwards at once when it is not
+// throttled.
+const bufferSize = 32 * 1024
+
+// Proxy forwards every connection accepted on its listen address to its
+// target. It is an ifrit.Runner.
+type Proxy struct {
+	listenAddress string
+	target        string
+
+	mutex       sync.Mutex
+	connections map[*connection]struct{}
+	latency     time.Duration
+	bandwidth   int
+	reject      bool
+
+	// flowing is closed while data is forwarded, and open while the proxy
+	// is blackholed
+	flowing chan struct{}
+}
+
+// New returns a Proxy that listens on listenAddress, for example
+// "127.0.0.1:8889", and forwards to target once it is run.
+func New(listenAddress, target string) *Proxy {
+	flowing := make(chan struct{})
+	close(flowing)
+
+	return &Proxy{
+		listenAddress: listenAddress,
+		target:        target,
+		flowing:       flowing,
+	}
+}
+
+// Address is the address clients connect to instead of the target.
+func (p *Proxy) Address() string {
+	return p.listenAddress
+}
+
+// Target is the address the proxy forwards to.
+func (p *Proxy) Target() string {
+	return p.target
+}
+
+// Run accepts connections until it is signalled, and then closes every open
+// connection.
+func (p *Proxy) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	listener, err := net.Listen("tcp", p.listenAddress)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	if p.connections == nil {
+		p.connections = map[*connection]struct{}{}
+	}
+	p.mutex.Unlock()
+
+	accepted := make(chan error, 1)
+	go func() {
+		accepted <- p.accept(listener)
+	}()
+
+	close(ready)
+
+	select {
+	case <-signals:
+		listener.Close()
+		<-accepted
+		p.stop()
+		return nil
+	case err := <-accepted:
+		p.stop()
+		return err
+	}
+}
+
+func (p *Proxy) accept(listener net.Listener) error {
+	for {
+		client, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		p.mutex.Lock()
+		reject := p.reject
+		p.mutex.Unlock()
+
+		if reject {
+			resetAndClose(client)
+			continue
+		}
+
+		go p.forward(client)
+	}
+}
+
+func (p *Proxy) forward(client net.Conn) {
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		resetAndClose(client)
+		return
+	}
+
+	conn := &connection{client: client, server: server, closed: make(chan struct{})}
+	if !p.track(conn) {
+		conn.close(false)
+		return
+	}
+	defer p.untrack(conn)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		p.copy(conn, server, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		p.copy(conn, client, server)
+		done <- struct{}{}
+	}()
+
+	// once either side is done, so is the connection
+	<-done
+	conn.close(false)
+	<-done
+}
+
+// copy forwards from src to dst of conn with the faults in effect when each
+// chunk arrives.
+func (p *Proxy) copy(conn *connection, dst io.Writer, src io.Reader) {
+	buffer := make([]byte, bufferSize)
+	for {
+		// a blackholed proxy stops reading, so that the data queues up
+		// unacknowledged, as it would behind a partition, and is forwarded
+		// once the proxy heals
+		if !p.waitForFlow(conn) {
+			return
+		}
+		_, bandwidth := p.faults()
+
+		chunk := buffer
+		if bandwidth > 0 {
+			// throttled chunks are small enough to be sent ten times a second
+			chunk = buffer[:clamp(bandwidth/10, 1, bufferSize)]
+		}
+
+		n, err := src.Read(chunk)
+		if n > 0 {
+			// a chunk read just before the proxy was blackholed is held
+			// back as well
+			if !p.waitForFlow(conn) {
+				return
+			}
+
+			latency, bandwidth := p.faults()
+			delay := latency
+			if bandwidth > 0 {
+				delay += time.Duration(n) * time.Second / time.Duration(bandwidth)
+			}
+			time.Sleep(delay)
+
+			_, writeErr := dst.Write(chunk[:n])
+			if writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *Proxy) faults() (time.Duration, int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.latency, p.bandwidth
+}
+
+// waitForFlow waits until the proxy is not blackholed, and returns false if
+// conn is closed first.
+func (p *Proxy) waitForFlow(conn *connection) bool {
+	p.mutex.Lock()
+	flowing := p.flowing
+	p.mutex.Unlock()
+
+	select {
+	case <-flowing:
+		return true
+	case <-conn.closed:
+		return false
+	}
+}
+
+// setBlackhole must be called with the mutex held.
+func (p *Proxy) setBlackhole(enabled bool) {
+	select {
+	case <-p.flowing:
+		if enabled {
+			p.flowing = make(chan struct{})
+		}
+	default:
+		if !enabled {
+			close(p.flowing)
+		}
+	}
+}
+
+// track adds conn to the open connections, unless the proxy has stopped.
+func (p *Proxy) track(conn *connection) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.connections == nil {
+		return false
+	}
+	p.connections[conn] = struct{}{}
+	return true
+}
+
+func (p *Proxy) untrack(conn *connection) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.connections, conn)
+}
+
+// stop closes every open connection and any that are still being opened.
+func (p *Proxy) stop() {
+	p.mutex.Lock()
+	connections := p.connections
+	p.connections = nil
+	p.mutex.Unlock()
+
+	for conn := range connections {
+		conn.close(false)
+	}
+}
+
+func (p *Proxy) closeConnections(reset bool) {
+	p.mutex.Lock()
+	connections := p.connections
+	if connections != nil {
+		p.connections = map[*connection]struct{}{}
+	}
+	p.mutex.Unlock()
+
+	for conn := range connections {
+		conn.close(reset)
+	}
+}
+
+// SetLatency delays every chunk of data by latency, in both directions.
+func (p *Proxy) SetLatency(latency time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.latency = latency
+}
+
+// SetBandwidth limits each direction of every connection to bytesPerSecond.
+// Zero removes the limit.
+func (p *Proxy) SetBandwidth(bytesPerSecond int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.bandwidth = bytesPerSecond
+}
+
+// Blackhole stops forwarding data while enabled, as with a network partition
+// that drops packets. Connections stay open and new ones are still accepted;
+// the proxy stops reading from them, so that their data is held back and
+// delivered in order once the blackhole is disabled, as TCP would retransmit
+// it after the partition.
+func (p *Proxy) Blackhole(enabled bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.setBlackhole(enabled)
+}
+
+// RejectConnections resets every new connection while enabled, as when the
+// target is down. Open connections are not affected.
+func (p *Proxy) RejectConnections(enabled bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.reject = enabled
+}
+
+// DropConnections closes every open connection gracefully.
+func (p *Proxy) DropConnections() {
+	p.closeConnections(false)
+}
+
+// ResetConnections closes every open connection with a TCP reset.
+func (p *Proxy) ResetConnections() {
+	p.closeConnections(true)
+}
+
+// Heal removes every fault. Data held back by a blackhole is delivered;
+// connections that were dropped or reset stay closed.
+func (p *Proxy) Heal() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.latency = 0
+	p.bandwidth = 0
+	p.reject = false
+	p.setBlackhole(false)
+}
+
+// connection is a client connection and the connection to the target it is
+// forwarded to.
+type connection struct {
+	client net.Conn
+	server net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *connection) close(reset bool) {
+	c.once.Do(func() {
+		close(c.closed)
+		if reset {
+			resetAndClose(c.client)
+			resetAndClose(c.server)
+			return
+		}
+		c.client.Close()
+		c.server.Close()
+	})
+}
+
+// resetAndClose closes conn with a TCP reset instead of a FIN.
+func resetAndClose(conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		// #nosec G104 - the connection is closed either way
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+func clamp(value, lower, upper int) int {
+	if value < lower {
+		return lower
+	}
+	if value > upper {
+		return upper
+	}
+	return value
+}
@@ -0,0 +1,167 @@
+package faultproxy_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/faultproxy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Proxy", func() {
+	var (
+		echoListener net.Listener
+		proxy        *faultproxy.Proxy
+		process      ifrit.Process
+		conn         net.Conn
+	)
+
+	roundTrip := func(message string) (string, error) {
+		_, err := conn.Write([]byte(message + "\n"))
+		if err != nil {
+			return "", err
+		}
+		return bufio.NewReader(conn).ReadString('\n')
+	}
+
+	BeforeEach(func() {
+		var err error
+		echoListener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			for {
+				echoConn, err := echoListener.Accept()
+				if err != nil {
+					return
+				}
+				go io.Copy(echoConn, echoConn)
+			}
+		}()
+
+		proxy = faultproxy.New(freeAddress(), echoListener.Addr().String())
+		process = ifrit.Invoke(proxy)
+
+		conn, err = net.Dial("tcp", proxy.Address())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		echoListener.Close()
+	})
+
+	It("forwards traffic in both directions", func() {
+		Expect(roundTrip("hello")).To(Equal("hello\n"))
+	})
+
+	It("delays traffic", func() {
+		proxy.SetLatency(100 * time.Millisecond)
+
+		start := time.Now()
+		Expect(roundTrip("hello")).To(Equal("hello\n"))
+		Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+	})
+
+	It("throttles traffic", func() {
+		proxy.SetBandwidth(10000)
+
+		message := strings.Repeat("x", 2000)
+		start := time.Now()
+		Expect(roundTrip(message)).To(Equal(message + "\n"))
+		Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+
+	It("black-holes traffic until healed, and then delivers what was held back", func() {
+		proxy.Blackhole(true)
+
+		Expect(conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))).To(Succeed())
+		_, err := roundTrip("held")
+		Expect(err).To(MatchError(os.ErrDeadlineExceeded))
+
+		proxy.Heal()
+		Expect(conn.SetReadDeadline(time.Time{})).To(Succeed())
+		Expect(bufio.NewReader(conn).ReadString('\n')).To(Equal("held\n"))
+		Expect(roundTrip("hello")).To(Equal("hello\n"))
+	})
+
+	It("holds back data read just before it was black-holed", func() {
+		Expect(roundTrip("hello")).To(Equal("hello\n"))
+
+		proxy.Blackhole(true)
+		Expect(conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))).To(Succeed())
+		_, err := roundTrip("held")
+		Expect(err).To(MatchError(os.ErrDeadlineExceeded))
+
+		proxy.Blackhole(false)
+		Expect(conn.SetReadDeadline(time.Time{})).To(Succeed())
+		Expect(bufio.NewReader(conn).ReadString('\n')).To(Equal("held\n"))
+	})
+
+	It("closes black-holed connections when stopped", func() {
+		proxy.Blackhole(true)
+		_, err := conn.Write([]byte("held\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+
+		// the unread data makes the close a reset rather than an EOF
+		_, err = bufio.NewReader(conn).ReadString('\n')
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("drops open connections", func() {
+		Expect(roundTrip("hello")).To(Equal("hello\n"))
+		proxy.DropConnections()
+
+		_, err := bufio.NewReader(conn).ReadString('\n')
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("resets open connections", func() {
+		Expect(roundTrip("hello")).To(Equal("hello\n"))
+		proxy.ResetConnections()
+
+		_, err := bufio.NewReader(conn).ReadString('\n')
+		Expect(err).To(HaveOccurred())
+		Expect(err).NotTo(Equal(io.EOF))
+	})
+
+	It("rejects new connections until healed", func() {
+		// make sure the open connection was accepted before rejecting
+		Expect(roundTrip("hello")).To(Equal("hello\n"))
+		proxy.RejectConnections(true)
+
+		rejected, err := net.Dial("tcp", proxy.Address())
+		Expect(err).NotTo(HaveOccurred())
+		defer rejected.Close()
+		_, err = bufio.NewReader(rejected).ReadString('\n')
+		Expect(err).To(HaveOccurred())
+
+		Expect(roundTrip("hello")).To(Equal("hello\n"))
+
+		proxy.Heal()
+		accepted, err := net.Dial("tcp", proxy.Address())
+		Expect(err).NotTo(HaveOccurred())
+		defer accepted.Close()
+		_, err = accepted.Write([]byte("hello\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bufio.NewReader(accepted).ReadString('\n')).To(Equal("hello\n"))
+	})
+})
+
+func freeAddress() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer listener.Close()
+	return listener.Addr().String()
+}
@@ -0,0 +1 @@
+package faultproxy // import "code.cloudfoundry.org/inigo/helpers/faultproxy"
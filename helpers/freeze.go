@@ -0,0 +1,50 @@
+package helpers
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/gomega"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+// Freeze stops the runner's process and all of its descendants with SIGSTOP,
+// so that the component stays alive, holding its connections, but does
+// nothing. Thaw it before stopping it, as a frozen process cannot shut down.
+func Freeze(runner *ginkgomon.Runner) {
+	pid, err := runnerPid(runner)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(freezeProcessTree(pid)).To(Succeed(), "freezing %s", runner.Name)
+}
+
+// Thaw resumes a process tree frozen by Freeze with SIGCONT.
+func Thaw(runner *ginkgomon.Runner) {
+	pid, err := runnerPid(runner)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(thawProcessTree(pid)).To(Succeed(), "thawing %s", runner.Name)
+}
+
+// FreezeFor freezes the runner's process tree and thaws it after duration.
+// The returned channel is closed once it is thawed.
+func FreezeFor(runner *ginkgomon.Runner, duration time.Duration) <-chan struct{} {
+	Freeze(runner)
+
+	pid, _ := runnerPid(runner)
+	thawed := make(chan struct{})
+	go func() {
+		defer close(thawed)
+		time.Sleep(duration)
+
+		// the process tree may have been thawed, or killed, in the meantime
+		// #nosec G104
+		thawProcessTree(pid)
+	}()
+	return thawed
+}
+
+func runnerPid(runner *ginkgomon.Runner) (int, error) {
+	if runner.Command == nil || runner.Command.Process == nil {
+		return 0, errors.New("runner " + runner.Name + " has not been started")
+	}
+	return runner.Command.Process.Pid, nil
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// freezeProcessTree stops pid before its descendants, so that it cannot
+// start new ones while they are being stopped.
+func freezeProcessTree(pid int) error {
+	pids, err := processTree(pid)
+	if err != nil {
+		return err
+	}
+
+	for _, pid := range pids {
+		err := syscall.Kill(pid, syscall.SIGSTOP)
+		if err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+// thawProcessTree resumes the descendants of pid before pid itself, so that
+// it finds them running.
+func thawProcessTree(pid int) error {
+	pids, err := processTree(pid)
+	if err != nil {
+		return err
+	}
+
+	for i := len(pids) - 1; i >= 0; i-- {
+		err := syscall.Kill(pids[i], syscall.SIGCONT)
+		if err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+// processTree returns pid and its descendants, parents before children.
+func processTree(pid int) ([]int, error) {
+	output, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[int][]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		child, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		parent, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		children[parent] = append(children[parent], child)
+	}
+
+	tree := []int{pid}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree, nil
+}
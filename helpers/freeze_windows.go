@@ -0,0 +1,15 @@
+//go:build windows
+
+package helpers
+
+import "errors"
+
+var errFreezeUnsupported = errors.New("freezing processes is not supported on windows")
+
+func freezeProcessTree(pid int) error {
+	return errFreezeUnsupported
+}
+
+func thawProcessTree(pid int) error {
+	return errFreezeUnsupported
+}
@@ -0,0 +1,421 @@
+// Package invariants checks the BBS state for violations of properties that
+// must hold at all times, not only at the points a spec polls, such as no
+// instance running beside its replacement.
+package invariants
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	// DefaultInterval is how often the BBS state is listed when a Config does
+	// not say.
+	DefaultInterval = time.Second
+
+	// DefaultGrace is how long a state that convergence is expected to fix
+	// may last when a Config does not say. It is twice the converge repeat
+	// interval the ComponentMaker gives the BBS.
+	DefaultGrace = time.Minute
+
+	// resubscribeInterval is how often an event stream that ended is
+	// subscribed to again.
+	resubscribeInterval = 500 * time.Millisecond
+)
+
+// Invariant names a property of the BBS state.
+type Invariant string
+
+const (
+	// UniqueRunningInstances is violated when an evacuating or suspect
+	// instance runs beside the ordinary running instance at its index. An
+	// evacuation on another cell, or the loss of the suspect instance's cell,
+	// explains that for the grace period, after which the instance should
+	// have been retired for its replacement. Otherwise, that is for an
+	// evacuating instance on the ordinary instance's cell or a suspect
+	// instance on a present cell, it is violated at once.
+	UniqueRunningInstances Invariant = "unique-running-instances"
+
+	// RunningWithinDesired is violated when more ordinary instances of an
+	// LRP run than are desired for longer than the grace period.
+	RunningWithinDesired Invariant = "running-within-desired"
+
+	// TasksOnPresentCells is violated when a task is running on a cell that
+	// is not present for longer than the grace period.
+	TasksOnPresentCells Invariant = "tasks-on-present-cells"
+
+	// ActualLRPsOnPresentCells is violated when an ordinary or evacuating
+	// instance is claimed by or running on a cell that is not present for
+	// longer than the grace period. Suspect instances are on such cells by
+	// definition.
+	ActualLRPsOnPresentCells Invariant = "actual-lrps-on-present-cells"
+)
+
+// Violation is an invariant that did not hold for the LRP instance or task
+// named by Key, from Since until it was reported.
+type Violation struct {
+	Invariant Invariant
+	Key       string
+	Since     time.Time
+	Message   string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s since %s: %s", v.Invariant, v.Key, v.Since.Format(time.RFC3339Nano), v.Message)
+}
+
+// Config is how often a Checker checks and how long it waits for
+// convergence.
+type Config struct {
+	Interval time.Duration
+	Grace    time.Duration
+}
+
+// Checker is an ifrit.Runner that checks every invariant each interval and
+// after every BBS instance and task event, until it is signalled. Listing
+// errors, for example while the BBS is down, are logged and skipped.
+type Checker struct {
+	logger lager.Logger
+	client bbs.Client
+	config Config
+
+	done chan struct{}
+
+	mutex      sync.Mutex
+	sources    []events.EventSource
+	allowed    map[Invariant]bool
+	firstSeen  map[string]time.Time
+	reported   map[string]bool
+	violations []Violation
+}
+
+// New returns a Checker of the state served by client.
+func New(logger lager.Logger, client bbs.Client, config Config) *Checker {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Grace <= 0 {
+		config.Grace = DefaultGrace
+	}
+
+	return &Checker{
+		logger:    logger.Session("bbs-invariants"),
+		client:    client,
+		config:    config,
+		done:      make(chan struct{}),
+		allowed:   map[Invariant]bool{},
+		firstSeen: map[string]time.Time{},
+		reported:  map[string]bool{},
+	}
+}
+
+// Allow stops checking invariants that a spec violates on purpose, for
+// example by running an LRP on a cell it then removes.
+func (c *Checker) Allow(invariants ...Invariant) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, invariant := range invariants {
+		c.allowed[invariant] = true
+	}
+}
+
+// Violations returns every violation found so far, each once, in the order
+// they were found.
+func (c *Checker) Violations() []Violation {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]Violation{}, c.violations...)
+}
+
+// Err describes every violation found so far, or is nil if there are none.
+func (c *Checker) Err() error {
+	violations := c.Violations()
+	if len(violations) == 0 {
+		return nil
+	}
+
+	descriptions := make([]string, len(violations))
+	for i, violation := range violations {
+		descriptions[i] = violation.String()
+	}
+	return fmt.Errorf("%d BBS invariant violations:\n%s", len(violations), strings.Join(descriptions, "\n"))
+}
+
+func (c *Checker) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	defer c.closeSources()
+	defer close(c.done)
+
+	triggers := make(chan struct{}, 1)
+	go c.watch(c.client.SubscribeToInstanceEvents, triggers)
+	go c.watch(c.client.SubscribeToTaskEvents, triggers)
+
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	c.Check()
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+			c.Check()
+		case <-triggers:
+			c.Check()
+		}
+	}
+}
+
+// watch triggers a check for every event of the stream returned by
+// subscribe, subscribing again whenever the stream ends.
+func (c *Checker) watch(subscribe func(lager.Logger) (events.EventSource, error), triggers chan<- struct{}) {
+	for {
+		source, err := subscribe(c.logger)
+		if err == nil {
+			if !c.addSource(source) {
+				return
+			}
+
+			for {
+				_, err := source.Next()
+				if err != nil {
+					break
+				}
+
+				select {
+				case triggers <- struct{}{}:
+				default:
+				}
+			}
+		}
+
+		select {
+		case <-c.done:
+			return
+		case <-time.After(resubscribeInterval):
+		}
+	}
+}
+
+// addSource keeps source to be closed when the checker stops, or closes it
+// and returns false if the checker has already stopped.
+func (c *Checker) addSource(source events.EventSource) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	select {
+	case <-c.done:
+		// #nosec G104 - the checker is done with the stream either way
+		source.Close()
+		return false
+	default:
+	}
+
+	c.sources = append(c.sources, source)
+	return true
+}
+
+func (c *Checker) closeSources() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, source := range c.sources {
+		// #nosec G104 - the checker is done with the stream either way
+		source.Close()
+	}
+	c.sources = nil
+}
+
+// observation is a state that violates an invariant, or will once it
+// outlasts the grace period.
+type observation struct {
+	invariant Invariant
+	key       string
+	message   string
+	graced    bool
+}
+
+// Check lists the BBS state once and records the invariants it violates.
+func (c *Checker) Check() {
+	cells, err := c.client.Cells(c.logger, "")
+	if err != nil {
+		c.logger.Error("failed-to-list-cells", err)
+		return
+	}
+	desiredLRPs, err := c.client.DesiredLRPs(c.logger, "", models.DesiredLRPFilter{})
+	if err != nil {
+		c.logger.Error("failed-to-list-desired-lrps", err)
+		return
+	}
+	actualLRPs, err := c.client.ActualLRPs(c.logger, "", models.ActualLRPFilter{})
+	if err != nil {
+		c.logger.Error("failed-to-list-actual-lrps", err)
+		return
+	}
+	tasks, err := c.client.Tasks(c.logger, "")
+	if err != nil {
+		c.logger.Error("failed-to-list-tasks", err)
+		return
+	}
+
+	c.record(time.Now(), observe(cells, desiredLRPs, actualLRPs, tasks))
+}
+
+func observe(cells []*models.CellPresence, desiredLRPs []*models.DesiredLRP, actualLRPs []*models.ActualLRP, tasks []*models.Task) []observation {
+	observations := []observation{}
+
+	presentCells := map[string]bool{}
+	for _, cell := range cells {
+		presentCells[cell.CellId] = true
+	}
+
+	desiredInstances := map[string]int{}
+	for _, desiredLRP := range desiredLRPs {
+		desiredInstances[desiredLRP.ProcessGuid] = int(desiredLRP.Instances)
+	}
+
+	ordinaryRunning := map[string]*models.ActualLRP{}
+	othersRunning := map[string][]*models.ActualLRP{}
+	runningInstances := map[string]int{}
+	for _, lrp := range actualLRPs {
+		key := fmt.Sprintf("%s/%d", lrp.ProcessGuid, lrp.Index)
+
+		if lrp.CellId != "" && !presentCells[lrp.CellId] && lrp.Presence != models.ActualLRP_Suspect {
+			observations = append(observations, observation{
+				invariant: ActualLRPsOnPresentCells,
+				key:       key + "/" + lrp.InstanceGuid,
+				message:   fmt.Sprintf("%s instance is on cell %s, which is not present", strings.ToLower(lrp.State), lrp.CellId),
+				graced:    true,
+			})
+		}
+
+		if lrp.State != models.ActualLRPStateRunning {
+			continue
+		}
+		if lrp.Presence == models.ActualLRP_Ordinary {
+			ordinaryRunning[key] = lrp
+			runningInstances[lrp.ProcessGuid]++
+		} else {
+			othersRunning[key] = append(othersRunning[key], lrp)
+		}
+	}
+
+	indexKeys := make([]string, 0, len(othersRunning))
+	for key := range othersRunning {
+		indexKeys = append(indexKeys, key)
+	}
+	sort.Strings(indexKeys)
+
+	for _, key := range indexKeys {
+		ordinary, found := ordinaryRunning[key]
+		if !found {
+			continue
+		}
+
+		for _, other := range othersRunning[key] {
+			observations = append(observations, runningBeside(key, ordinary, other, presentCells))
+		}
+	}
+
+	processGuids := make([]string, 0, len(runningInstances))
+	for processGuid := range runningInstances {
+		processGuids = append(processGuids, processGuid)
+	}
+	sort.Strings(processGuids)
+
+	for _, processGuid := range processGuids {
+		running, desired := runningInstances[processGuid], desiredInstances[processGuid]
+		if running > desired {
+			observations = append(observations, observation{
+				invariant: RunningWithinDesired,
+				key:       processGuid,
+				message:   fmt.Sprintf("%d instances are running, but %d are desired", running, desired),
+				graced:    true,
+			})
+		}
+	}
+
+	for _, task := range tasks {
+		if task.State == models.Task_Running && !presentCells[task.CellId] {
+			observations = append(observations, observation{
+				invariant: TasksOnPresentCells,
+				key:       task.TaskGuid,
+				message:   fmt.Sprintf("task is running on cell %s, which is not present", task.CellId),
+				graced:    true,
+			})
+		}
+	}
+
+	return observations
+}
+
+// runningBeside observes other, an evacuating or suspect instance, running
+// beside ordinary, the ordinary running instance at its index.
+func runningBeside(key string, ordinary, other *models.ActualLRP, presentCells map[string]bool) observation {
+	presence := "suspect"
+	explained := !presentCells[other.CellId]
+	if other.Presence == models.ActualLRP_Evacuating {
+		presence = "evacuating"
+		explained = other.CellId != ordinary.CellId
+	}
+
+	message := fmt.Sprintf("%s instance on cell %s runs beside the ordinary instance on cell %s", presence, other.CellId, ordinary.CellId)
+	if !explained {
+		message += ", with no evacuation or lost cell to explain it"
+	}
+
+	return observation{
+		invariant: UniqueRunningInstances,
+		key:       key + "/" + other.InstanceGuid,
+		message:   message,
+		graced:    explained,
+	}
+}
+
+// record reports the observations that are violations at now. Graced
+// observations become violations once they have been seen by every check
+// for the grace period.
+func (c *Checker) record(now time.Time, observations []observation) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	firstSeen := map[string]time.Time{}
+	for _, observation := range observations {
+		if c.allowed[observation.invariant] {
+			continue
+		}
+
+		id := string(observation.invariant) + " " + observation.key
+		since, seen := c.firstSeen[id]
+		if !seen {
+			since = now
+		}
+		firstSeen[id] = since
+
+		if c.reported[id] || (observation.graced && now.Sub(since) < c.config.Grace) {
+			continue
+		}
+
+		c.reported[id] = true
+		violation := Violation{
+			Invariant: observation.invariant,
+			Key:       observation.key,
+			Since:     since,
+			Message:   observation.message,
+		}
+		c.violations = append(c.violations, violation)
+		c.logger.Info("violation", lager.Data{"violation": violation.String()})
+	}
+	c.firstSeen = firstSeen
+}
@@ -0,0 +1,209 @@
+package invariants_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers/invariants"
+	"code.cloudfoundry.org/lager/v3/lagertest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checker", func() {
+	const grace = 100 * time.Millisecond
+
+	var (
+		client  *fake_bbs.FakeClient
+		checker *invariants.Checker
+	)
+
+	actualLRP := func(processGuid string, index int32, cellID, state string, presence models.ActualLRP_Presence) *models.ActualLRP {
+		return &models.ActualLRP{
+			ActualLRPKey:         models.NewActualLRPKey(processGuid, index, "inigo"),
+			ActualLRPInstanceKey: models.NewActualLRPInstanceKey(processGuid+"-instance", cellID),
+			State:                state,
+			Presence:             presence,
+		}
+	}
+
+	BeforeEach(func() {
+		client = &fake_bbs.FakeClient{}
+		client.CellsReturns([]*models.CellPresence{{CellId: "cell-a"}, {CellId: "cell-b"}}, nil)
+		client.DesiredLRPsReturns([]*models.DesiredLRP{{ProcessGuid: "web", Instances: 1}}, nil)
+
+		checker = invariants.New(lagertest.NewTestLogger("test"), client, invariants.Config{Grace: grace})
+	})
+
+	It("finds no violations in a converged state", func() {
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-a", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+		client.TasksReturns([]*models.Task{{TaskGuid: "task", State: models.Task_Running, CellId: "cell-b"}}, nil)
+
+		checker.Check()
+		time.Sleep(grace)
+		checker.Check()
+
+		Expect(checker.Violations()).To(BeEmpty())
+		Expect(checker.Err()).NotTo(HaveOccurred())
+	})
+
+	It("reports an evacuating instance running beside the ordinary one on the same cell at once", func() {
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-a", models.ActualLRPStateRunning, models.ActualLRP_Evacuating),
+			actualLRP("web", 0, "cell-a", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+
+		checker.Check()
+
+		Expect(checker.Violations()).To(ConsistOf(SatisfyAll(
+			HaveField("Invariant", invariants.UniqueRunningInstances),
+			HaveField("Key", "web/0/web-instance"),
+			HaveField("Message", ContainSubstring("evacuating instance on cell cell-a runs beside the ordinary instance on cell cell-a")),
+		)))
+		Expect(checker.Err()).To(MatchError(ContainSubstring("unique-running-instances web/0/web-instance")))
+	})
+
+	It("reports a suspect instance on a present cell running beside the ordinary one at once", func() {
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-a", models.ActualLRPStateRunning, models.ActualLRP_Suspect),
+			actualLRP("web", 0, "cell-b", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+
+		checker.Check()
+
+		Expect(checker.Violations()).To(ConsistOf(SatisfyAll(
+			HaveField("Invariant", invariants.UniqueRunningInstances),
+			HaveField("Message", ContainSubstring("no evacuation or lost cell to explain it")),
+		)))
+	})
+
+	It("allows an evacuating instance to run beside its replacement for the grace period", func() {
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-a", models.ActualLRPStateRunning, models.ActualLRP_Evacuating),
+			actualLRP("web", 0, "cell-b", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+
+		checker.Check()
+		Expect(checker.Violations()).To(BeEmpty())
+
+		time.Sleep(grace)
+		checker.Check()
+		Expect(checker.Violations()).To(ConsistOf(SatisfyAll(
+			HaveField("Invariant", invariants.UniqueRunningInstances),
+			HaveField("Message", ContainSubstring("evacuating instance on cell cell-a runs beside the ordinary instance on cell cell-b")),
+		)))
+	})
+
+	It("reports more running instances than desired once they outlast the grace period", func() {
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-a", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+			actualLRP("web", 1, "cell-b", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+
+		checker.Check()
+		Expect(checker.Violations()).To(BeEmpty())
+
+		time.Sleep(grace)
+		checker.Check()
+		Expect(checker.Violations()).To(ConsistOf(SatisfyAll(
+			HaveField("Invariant", invariants.RunningWithinDesired),
+			HaveField("Key", "web"),
+		)))
+	})
+
+	It("forgets states that converge within the grace period", func() {
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-gone", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+		checker.Check()
+
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-a", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+		checker.Check()
+
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-gone", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+		time.Sleep(grace)
+		checker.Check()
+
+		Expect(checker.Violations()).To(BeEmpty())
+	})
+
+	It("reports instances and tasks on cells that are not present", func() {
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-gone", models.ActualLRPStateClaimed, models.ActualLRP_Ordinary),
+		}, nil)
+		client.TasksReturns([]*models.Task{{TaskGuid: "task", State: models.Task_Running, CellId: "cell-gone"}}, nil)
+
+		checker.Check()
+		time.Sleep(grace)
+		checker.Check()
+
+		Expect(checker.Violations()).To(ConsistOf(
+			SatisfyAll(
+				HaveField("Invariant", invariants.ActualLRPsOnPresentCells),
+				HaveField("Message", ContainSubstring("claimed instance is on cell cell-gone")),
+			),
+			SatisfyAll(
+				HaveField("Invariant", invariants.TasksOnPresentCells),
+				HaveField("Key", "task"),
+			),
+		))
+	})
+
+	It("allows suspect instances on cells that are not present to run beside their replacements for the grace period", func() {
+		client.ActualLRPsReturns([]*models.ActualLRP{
+			actualLRP("web", 0, "cell-gone", models.ActualLRPStateRunning, models.ActualLRP_Suspect),
+			actualLRP("web", 0, "cell-a", models.ActualLRPStateRunning, models.ActualLRP_Ordinary),
+		}, nil)
+
+		checker.Check()
+		Expect(checker.Violations()).To(BeEmpty())
+
+		time.Sleep(grace)
+		checker.Check()
+		Expect(checker.Violations()).To(ConsistOf(SatisfyAll(
+			HaveField("Invariant", invariants.UniqueRunningInstances),
+			HaveField("Message", ContainSubstring("suspect instance on cell cell-gone runs beside the ordinary instance on cell cell-a")),
+		)))
+	})
+
+	It("reports each violation once", func() {
+		client.TasksReturns([]*models.Task{{TaskGuid: "task", State: models.Task_Running, CellId: "cell-gone"}}, nil)
+
+		for i := 0; i < 3; i++ {
+			checker.Check()
+			time.Sleep(grace)
+		}
+
+		Expect(checker.Violations()).To(HaveLen(1))
+	})
+
+	It("does not check allowed invariants", func() {
+		checker.Allow(invariants.TasksOnPresentCells)
+		client.TasksReturns([]*models.Task{{TaskGuid: "task", State: models.Task_Running, CellId: "cell-gone"}}, nil)
+
+		checker.Check()
+		time.Sleep(grace)
+		checker.Check()
+
+		Expect(checker.Violations()).To(BeEmpty())
+	})
+
+	It("skips checks that fail to list the state", func() {
+		client.CellsReturns(nil, errors.New("bbs is down"))
+		client.TasksReturns([]*models.Task{{TaskGuid: "task", State: models.Task_Running, CellId: "cell-gone"}}, nil)
+
+		checker.Check()
+		time.Sleep(grace)
+		checker.Check()
+
+		Expect(checker.Violations()).To(BeEmpty())
+	})
+})
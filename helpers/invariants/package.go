@@ -0,0 +1 @@
+package invariants // import "code.cloudfoundry.org/inigo/helpers/invariants"
@@ -0,0 +1,98 @@
+// Package lagerlog parses the lager output of components and matches the
+// entries they logged, regardless of the time format they log with and of
+// the order of the fields in each line.
+package lagerlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// entry is a line in either lager format: the original one, with a numeric
+// log_level, or the RFC3339 one, with a named level.
+type entry struct {
+	Timestamp string          `json:"timestamp"`
+	Source    string          `json:"source"`
+	Message   string          `json:"message"`
+	LogLevel  *lager.LogLevel `json:"log_level"`
+	Level     string          `json:"level"`
+	Data      lager.Data      `json:"data"`
+}
+
+// Parse returns the lager entries in contents, in the order they were
+// logged. Lines that are not lager entries are skipped.
+func Parse(contents []byte) []lager.LogFormat {
+	entries := []lager.LogFormat{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var e entry
+		if json.Unmarshal(line, &e) != nil || e.Message == "" {
+			continue
+		}
+
+		level, ok := e.logLevel()
+		if !ok {
+			continue
+		}
+
+		entries = append(entries, lager.LogFormat{
+			Timestamp: e.Timestamp,
+			Source:    e.Source,
+			Message:   e.Message,
+			LogLevel:  level,
+			Data:      e.Data,
+		})
+	}
+
+	return entries
+}
+
+func (e entry) logLevel() (lager.LogLevel, bool) {
+	if e.LogLevel != nil {
+		return *e.LogLevel, true
+	}
+
+	switch strings.ToLower(e.Level) {
+	case "debug":
+		return lager.DEBUG, true
+	case "info":
+		return lager.INFO, true
+	case "error":
+		return lager.ERROR, true
+	case "fatal":
+		return lager.FATAL, true
+	}
+	return 0, false
+}
+
+// Entries returns the lager entries in actual, which may be a
+// gbytes.BufferProvider such as a ginkgomon runner, a *gbytes.Buffer, a
+// []byte or a string. Reading a buffer does not move its read cursor.
+func Entries(actual interface{}) ([]lager.LogFormat, error) {
+	switch actual := actual.(type) {
+	case *gbytes.Buffer:
+		return Parse(actual.Contents()), nil
+	case gbytes.BufferProvider:
+		return Parse(actual.Buffer().Contents()), nil
+	case []byte:
+		return Parse(actual), nil
+	case string:
+		return Parse([]byte(actual)), nil
+	case []lager.LogFormat:
+		return actual, nil
+	}
+	return nil, fmt.Errorf("lagerlog expects a gbytes.BufferProvider, *gbytes.Buffer, []byte or string, got %T", actual)
+}
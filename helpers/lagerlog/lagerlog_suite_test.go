@@ -0,0 +1,13 @@
+package lagerlog_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLagerlog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lagerlog Suite")
+}
@@ -0,0 +1,98 @@
+package lagerlog_test
+
+import (
+	"code.cloudfoundry.org/inigo/helpers/lagerlog"
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+const (
+	epochLine   = `{"timestamp":"1700000000.123456789","source":"rep","message":"rep.executing-container-operation.starting","log_level":1,"data":{"container-guid":"guid-1","index":2,"session":"7"}}`
+	rfc3339Line = `{"timestamp":"2023-11-14T22:13:20.123456789Z","level":"error","source":"bbs","message":"bbs.request.failed","data":{"error":"boom","trace-id":"abc"}}`
+)
+
+var _ = Describe("Lagerlog", func() {
+	Describe("Parse", func() {
+		It("parses entries in either format and skips other lines", func() {
+			entries := lagerlog.Parse([]byte(epochLine + "\nnot a log line\n{\"not\":\"lager\"}\n" + rfc3339Line + "\n"))
+			Expect(entries).To(HaveLen(2))
+
+			Expect(entries[0].Source).To(Equal("rep"))
+			Expect(entries[0].Message).To(Equal("rep.executing-container-operation.starting"))
+			Expect(entries[0].LogLevel).To(Equal(lager.INFO))
+			Expect(entries[0].Data).To(HaveKeyWithValue("container-guid", "guid-1"))
+
+			Expect(entries[1].Timestamp).To(Equal("2023-11-14T22:13:20.123456789Z"))
+			Expect(entries[1].LogLevel).To(Equal(lager.ERROR))
+			Expect(entries[1].Data).To(HaveKeyWithValue("trace-id", "abc"))
+		})
+	})
+
+	Describe("Entries", func() {
+		It("reads buffers without moving their read cursor", func() {
+			buffer := gbytes.BufferWithBytes([]byte(epochLine + "\n"))
+
+			entries, err := lagerlog.Entries(buffer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+			Expect(buffer).To(gbytes.Say("executing-container-operation"))
+		})
+
+		It("errors on anything else", func() {
+			_, err := lagerlog.Entries(42)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("HaveLoggedMessage", func() {
+		var buffer *gbytes.Buffer
+
+		BeforeEach(func() {
+			buffer = gbytes.BufferWithBytes([]byte(rfc3339Line + "\n" + epochLine + "\n"))
+		})
+
+		It("matches the message regardless of the order of the entries", func() {
+			Expect(buffer).To(lagerlog.HaveLoggedMessage("rep.executing-container-operation.starting"))
+			Expect(buffer).To(lagerlog.HaveLoggedMessage("bbs.request.failed"))
+			Expect(buffer).NotTo(lagerlog.HaveLoggedMessage("rep.executing-container-operation"))
+		})
+
+		It("matches data by value or with a matcher", func() {
+			Expect(buffer).To(lagerlog.HaveLoggedMessage(
+				"rep.executing-container-operation.starting",
+				lagerlog.WithData("container-guid", "guid-1"),
+				lagerlog.WithData("index", 2),
+			))
+			Expect(buffer).To(lagerlog.HaveLoggedMessage(
+				"bbs.request.failed",
+				lagerlog.WithData("error", ContainSubstring("boo")),
+			))
+			Expect(buffer).NotTo(lagerlog.HaveLoggedMessage(
+				"rep.executing-container-operation.starting",
+				lagerlog.WithData("container-guid", "guid-2"),
+			))
+		})
+
+		It("filters by source and level", func() {
+			Expect(buffer).To(lagerlog.HaveLoggedMessage("bbs.request.failed", lagerlog.WithSource("bbs"), lagerlog.WithLevel(lager.ERROR)))
+			Expect(buffer).NotTo(lagerlog.HaveLoggedMessage("bbs.request.failed", lagerlog.WithSource("rep")))
+			Expect(buffer).NotTo(lagerlog.HaveLoggedMessage("bbs.request.failed", lagerlog.WithLevel(lager.INFO)))
+		})
+
+		It("describes the entries with the message on failure", func() {
+			matcher := lagerlog.HaveLoggedMessage("bbs.request.failed", lagerlog.WithData("trace-id", "xyz"))
+			Expect(matcher.Match(buffer)).To(BeFalse())
+			Expect(matcher.FailureMessage(buffer)).To(ContainSubstring(`"trace-id":"abc"`))
+		})
+	})
+
+	Describe("HaveLogged", func() {
+		It("matches an entry with any message", func() {
+			buffer := gbytes.BufferWithBytes([]byte(rfc3339Line + "\n"))
+			Expect(buffer).To(lagerlog.HaveLogged(lagerlog.WithData("trace-id", "abc")))
+			Expect(buffer).NotTo(lagerlog.HaveLogged(lagerlog.WithData("trace-id", "xyz")))
+		})
+	})
+})
@@ -0,0 +1,208 @@
+package lagerlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+)
+
+// Filter narrows the entries a matcher searches.
+type Filter func(*LoggedMatcher)
+
+// WithSource only searches the entries logged by source, for example "rep".
+func WithSource(source string) Filter {
+	return func(matcher *LoggedMatcher) {
+		matcher.source = source
+	}
+}
+
+// WithLevel only searches the entries logged at level.
+func WithLevel(level lager.LogLevel) Filter {
+	return func(matcher *LoggedMatcher) {
+		matcher.level = &level
+	}
+}
+
+// WithData only matches entries whose data has key. The value is either a
+// gomega matcher or a value that equals the data once both are converted to
+// JSON, so that WithData("index", 1) matches the float64 parsed from a log.
+func WithData(key string, value interface{}) Filter {
+	return func(matcher *LoggedMatcher) {
+		matcher.data = append(matcher.data, dataField{key: key, value: value})
+	}
+}
+
+type dataField struct {
+	key   string
+	value interface{}
+}
+
+// HaveLoggedMessage succeeds if actual, anything Entries accepts, has an
+// entry with message that passes every filter:
+//
+//	Eventually(cell.Rep).Should(lagerlog.HaveLoggedMessage(
+//		"rep.executing-container-operation",
+//		lagerlog.WithData("container-guid", guid),
+//	))
+func HaveLoggedMessage(message string, filters ...Filter) types.GomegaMatcher {
+	matcher := &LoggedMatcher{message: message}
+	for _, filter := range filters {
+		filter(matcher)
+	}
+	return matcher
+}
+
+// HaveLogged succeeds if actual has an entry, with any message, that passes
+// every filter.
+func HaveLogged(filters ...Filter) types.GomegaMatcher {
+	return HaveLoggedMessage("", filters...)
+}
+
+type LoggedMatcher struct {
+	message string
+	source  string
+	level   *lager.LogLevel
+	data    []dataField
+
+	// the entries that passed the source and level filters, for failure
+	// messages
+	searched []lager.LogFormat
+}
+
+func (matcher *LoggedMatcher) Match(actual interface{}) (bool, error) {
+	entries, err := Entries(actual)
+	if err != nil {
+		return false, err
+	}
+
+	matcher.searched = []lager.LogFormat{}
+	for _, entry := range entries {
+		if matcher.source != "" && entry.Source != matcher.source {
+			continue
+		}
+		if matcher.level != nil && entry.LogLevel != *matcher.level {
+			continue
+		}
+		matcher.searched = append(matcher.searched, entry)
+
+		if matcher.message != "" && entry.Message != matcher.message {
+			continue
+		}
+
+		matched, err := matcher.matchData(entry.Data)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (matcher *LoggedMatcher) matchData(data lager.Data) (bool, error) {
+	for _, field := range matcher.data {
+		value, ok := data[field.key]
+		if !ok {
+			return false, nil
+		}
+
+		if valueMatcher, ok := field.value.(types.GomegaMatcher); ok {
+			matched, err := valueMatcher.Match(value)
+			if err != nil || !matched {
+				return false, err
+			}
+			continue
+		}
+
+		expected, err := normalize(field.value)
+		if err != nil {
+			return false, err
+		}
+		if !reflect.DeepEqual(value, expected) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// normalize converts value to what it would be when parsed from a log.
+func normalize(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+func (matcher *LoggedMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s\nto have logged %s\n%s", format.Object(actual, 1), matcher.describe(), matcher.describeSearched())
+}
+
+func (matcher *LoggedMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected %s\nnot to have logged %s", format.Object(actual, 1), matcher.describe())
+}
+
+func (matcher *LoggedMatcher) describe() string {
+	parts := []string{}
+	if matcher.message != "" {
+		parts = append(parts, fmt.Sprintf("message %q", matcher.message))
+	} else {
+		parts = append(parts, "an entry")
+	}
+	if matcher.source != "" {
+		parts = append(parts, fmt.Sprintf("from source %q", matcher.source))
+	}
+	if matcher.level != nil {
+		parts = append(parts, fmt.Sprintf("at level %s", levelName(*matcher.level)))
+	}
+	for _, field := range matcher.data {
+		parts = append(parts, fmt.Sprintf("with data %q: %s", field.key, format.Object(field.value, 0)))
+	}
+	return strings.Join(parts, "\n  ")
+}
+
+// describeSearched lists the entries that had the expected message, or
+// counts the searched entries if none had it.
+func (matcher *LoggedMatcher) describeSearched() string {
+	if matcher.message == "" {
+		return fmt.Sprintf("but none of the %d entries searched had it", len(matcher.searched))
+	}
+
+	lines := []string{}
+	for _, entry := range matcher.searched {
+		if entry.Message != matcher.message {
+			continue
+		}
+		data, _ := json.Marshal(entry.Data)
+		lines = append(lines, fmt.Sprintf("  %s %s %s", levelName(entry.LogLevel), entry.Message, data))
+	}
+
+	if len(lines) == 0 {
+		return fmt.Sprintf("but none of the %d entries searched had it", len(matcher.searched))
+	}
+	return fmt.Sprintf("but the entries with that message were:\n%s", strings.Join(lines, "\n"))
+}
+
+func levelName(level lager.LogLevel) string {
+	switch level {
+	case lager.DEBUG:
+		return "debug"
+	case lager.INFO:
+		return "info"
+	case lager.ERROR:
+		return "error"
+	case lager.FATAL:
+		return "fatal"
+	}
+	return fmt.Sprintf("level-%d", level)
+}
@@ -0,0 +1 @@
+package lagerlog // import "code.cloudfoundry.org/inigo/helpers/lagerlog"
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	bbsconfig "code.cloudfoundry.org/bbs/cmd/bbs/config"
+	"code.cloudfoundry.org/inigo/helpers/faultproxy"
+	"code.cloudfoundry.org/inigo/world"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+// LocketLink is the connection of a single component to locket, through a
+// fault proxy, so that it can be partitioned from locket while every other
+// component keeps its own connection.
+type LocketLink struct {
+	proxy   *faultproxy.Proxy
+	process ifrit.Process
+}
+
+// StartLocketLink runs a fault proxy to locket. Configure the component with
+// RepConfig or BBSConfig to route its locket traffic through it.
+func StartLocketLink(componentMaker world.ComponentMaker) *LocketLink {
+	proxy := componentMaker.FaultProxy(componentMaker.Addresses().Locket)
+	return &LocketLink{
+		proxy:   proxy,
+		process: ginkgomon.Invoke(proxy),
+	}
+}
+
+// RepConfig points a rep at the link.
+func (l *LocketLink) RepConfig(cfg *repconfig.RepConfig) {
+	cfg.ClientLocketConfig.LocketAddress = l.proxy.Address()
+}
+
+// BBSConfig points a BBS at the link.
+func (l *LocketLink) BBSConfig(cfg *bbsconfig.BBSConfig) {
+	cfg.ClientLocketConfig.LocketAddress = l.proxy.Address()
+}
+
+// Partition drops all traffic between the component and locket, without
+// closing its connections, so that its locks and presence expire.
+func (l *LocketLink) Partition() {
+	l.proxy.Blackhole(true)
+}
+
+// Heal restores the link. Connections open during the partition resume, with
+// the data held back during it, unless the component gave up on them.
+func (l *LocketLink) Heal() {
+	l.proxy.Heal()
+}
+
+// Stop stops the proxy, closing the component's connections to locket.
+func (l *LocketLink) Stop() {
+	StopProcesses(l.process)
+}
@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/world"
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+)
+
+// BeBalancedAcrossZones succeeds for a slice of ActualLRPs that are all placed
+// on the given cells, with the number of instances in any two zones differing
+// by at most maxSkew.
+func BeBalancedAcrossZones(cells *world.Cells, maxSkew int) gomega.OmegaMatcher {
+	return &ZoneBalanceMatcher{
+		Cells:   cells,
+		MaxSkew: maxSkew,
+	}
+}
+
+type ZoneBalanceMatcher struct {
+	Cells   *world.Cells
+	MaxSkew int
+
+	distribution map[string]int
+}
+
+func (matcher *ZoneBalanceMatcher) Match(actual interface{}) (success bool, err error) {
+	lrps, ok := actual.([]models.ActualLRP)
+	if !ok {
+		return false, fmt.Errorf("BeBalancedAcrossZones expects a []models.ActualLRP, got\n%s", format.Object(actual, 1))
+	}
+
+	matcher.distribution = matcher.Cells.ZoneDistribution(lrps)
+	if matcher.distribution[""] > 0 {
+		return false, nil
+	}
+
+	min, max := -1, 0
+	for _, zone := range matcher.Cells.Zones() {
+		count := matcher.distribution[zone]
+		if min == -1 || count < min {
+			min = count
+		}
+		if count > max {
+			max = count
+		}
+	}
+
+	return max-min <= matcher.MaxSkew, nil
+}
+
+func (matcher *ZoneBalanceMatcher) FailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected instances to be spread across zones %v with a skew of at most %d, got\n%s", matcher.Cells.Zones(), matcher.MaxSkew, format.Object(matcher.distribution, 1))
+}
+
+func (matcher *ZoneBalanceMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected instances not to be spread across zones %v with a skew of at most %d, got\n%s", matcher.Cells.Zones(), matcher.MaxSkew, format.Object(matcher.distribution, 1))
+}
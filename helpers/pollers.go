@@ -1,11 +1,15 @@
 package helpers
 
 import (
+	"errors"
 	"strings"
 
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/serviceclient"
+	"code.cloudfoundry.org/inigo/world"
 	"code.cloudfoundry.org/lager/v3"
+	locketmodels "code.cloudfoundry.org/locket/models"
 
 	. "github.com/onsi/gomega"
 )
@@ -112,3 +116,27 @@ func LRPInstanceStatePoller(logger lager.Logger, client bbs.InternalClient, proc
 		return foundLRP.State
 	}
 }
+
+// LockOwnerPoller returns the owner of the locket lock under key, or "" while
+// nobody holds it. Any other error fetching the lock fails the spec.
+func LockOwnerPoller(logger lager.Logger, componentMaker world.ComponentMaker, key string) func() string {
+	return func() string {
+		owner, err := componentMaker.LockOwner(logger, key)
+		if errors.Is(err, locketmodels.ErrResourceNotFound) {
+			return ""
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return owner
+	}
+}
+
+// CellPresencePoller returns whether the cell has a presence in locket, that
+// is whether the BBS sees it as present rather than missing.
+func CellPresencePoller(logger lager.Logger, client serviceclient.ServiceClient, cellID string) func() bool {
+	return func() bool {
+		cells, err := client.Cells(logger)
+		Expect(err).NotTo(HaveOccurred())
+		_, present := cells[cellID]
+		return present
+	}
+}
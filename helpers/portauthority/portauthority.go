@@ -1,14 +1,27 @@
 package portauthority
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 type PortAllocator interface {
 	ClaimPorts(int) (uint16, error)
 }
 
+// PortReleaser is a PortAllocator that can also take ports back. It is kept
+// apart from PortAllocator so that allocators written against the original
+// interface still satisfy it.
+type PortReleaser interface {
+	PortAllocator
+	ReleasePorts(uint16, int) error
+}
+
 type portAllocator struct {
-	nextPort   uint16
-	endingPort uint16
+	startingPort uint16
+	nextPort     uint16
+	endingPort   uint16
+	released     map[uint16]bool
 }
 
 // New creates a new port allocator
@@ -16,13 +29,15 @@ type portAllocator struct {
 // endingPort indicates the maximum port number that this allocator may assign.
 //
 // returns a non-nil error if the ending port exceeds the IANA maximum of 65535.
-func New(startingPort, endingPort int) (PortAllocator, error) {
+func New(startingPort, endingPort int) (PortReleaser, error) {
 	if endingPort > 65535 {
 		return nil, errors.New("Invalid port range requested. Ports can only be numbers between 0-65535")
 	}
 	return &portAllocator{
-		nextPort:   uint16(startingPort),
-		endingPort: uint16(endingPort),
+		startingPort: uint16(startingPort),
+		nextPort:     uint16(startingPort),
+		endingPort:   uint16(endingPort),
+		released:     map[uint16]bool{},
 	}, nil
 }
 
@@ -35,16 +50,65 @@ func New(startingPort, endingPort int) (PortAllocator, error) {
 //
 // numPorts indicates the number of ports that will be claimed. The first claimed
 // port is returned, and the next numPorts-1 ports sequentially after that are yours
-// to use.
+// to use. Ports given back with ReleasePorts are handed out again before any
+// new ports are claimed.
 //
 // returns a non-nil error if there are not enough ports in the range compared to
 // the number requested.
 func (p *portAllocator) ClaimPorts(numPorts int) (uint16, error) {
+	if port, ok := p.claimReleasedPorts(numPorts); ok {
+		return port, nil
+	}
+
 	port := p.nextPort
-	if p.endingPort < port+uint16(numPorts-1) {
+	if int(port)+numPorts-1 > int(p.endingPort) {
 		return 0, errors.New("insufficient ports available")
 	}
 
 	p.nextPort = p.nextPort + uint16(numPorts)
 	return uint16(port), nil
 }
+
+// ReleasePorts gives back numPorts ports starting at port so that they can be
+// claimed again.
+//
+// returns a non-nil error if any of the ports was never claimed.
+func (p *portAllocator) ReleasePorts(port uint16, numPorts int) error {
+	if int(port)+numPorts-1 > int(p.endingPort) {
+		return fmt.Errorf("ports %d to %d are outside of the range", port, int(port)+numPorts-1)
+	}
+
+	for i := 0; i < numPorts; i++ {
+		current := port + uint16(i)
+		if current < p.startingPort || current >= p.nextPort || p.released[current] {
+			return fmt.Errorf("port %d was not claimed", current)
+		}
+	}
+
+	for i := 0; i < numPorts; i++ {
+		p.released[port+uint16(i)] = true
+	}
+	return nil
+}
+
+func (p *portAllocator) claimReleasedPorts(numPorts int) (uint16, bool) {
+	for port := p.startingPort; port < p.nextPort; port++ {
+		if !p.released[port] {
+			continue
+		}
+
+		available := 0
+		for available < numPorts && int(port)+available <= int(p.endingPort) && p.released[port+uint16(available)] {
+			available++
+		}
+		if available < numPorts {
+			continue
+		}
+
+		for i := 0; i < numPorts; i++ {
+			delete(p.released, port+uint16(i))
+		}
+		return port, true
+	}
+	return 0, false
+}
@@ -8,7 +8,7 @@ import (
 
 var _ = Describe("Portallocator", func() {
 	var (
-		allocator portauthority.PortAllocator
+		allocator portauthority.PortReleaser
 		port      uint16
 		err       error
 	)
@@ -72,6 +72,46 @@ var _ = Describe("Portallocator", func() {
 		})
 	})
 
+	Context("when ports are released", func() {
+		BeforeEach(func() {
+			port, err = allocator.ClaimPorts(3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(port).To(BeEquivalentTo(30))
+		})
+
+		It("hands them out again before claiming new ports", func() {
+			Expect(allocator.ReleasePorts(31, 2)).To(Succeed())
+
+			Expect(allocator.ClaimPorts(2)).To(BeEquivalentTo(31))
+			Expect(allocator.ClaimPorts(1)).To(BeEquivalentTo(33))
+		})
+
+		It("claims new ports when the released ports are not consecutive", func() {
+			Expect(allocator.ReleasePorts(30, 1)).To(Succeed())
+			Expect(allocator.ReleasePorts(32, 1)).To(Succeed())
+
+			Expect(allocator.ClaimPorts(2)).To(BeEquivalentTo(33))
+			Expect(allocator.ClaimPorts(1)).To(BeEquivalentTo(30))
+		})
+
+		It("errors when the ports were never claimed", func() {
+			Expect(allocator.ReleasePorts(33, 1)).To(MatchError("port 33 was not claimed"))
+		})
+
+		It("errors when the ports run past the end of the range", func() {
+			allocator, err = portauthority.New(65530, 65535)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(allocator.ClaimPorts(6)).To(BeEquivalentTo(65530))
+
+			Expect(allocator.ReleasePorts(65534, 10)).To(MatchError("ports 65534 to 65543 are outside of the range"))
+		})
+
+		It("errors when the ports were already released", func() {
+			Expect(allocator.ReleasePorts(30, 1)).To(Succeed())
+			Expect(allocator.ReleasePorts(30, 1)).To(MatchError("port 30 was not claimed"))
+		})
+	})
+
 	Context("when a range outside the port spec is requested", func() {
 		It("errors", func() {
 			allocator, err = portauthority.New(30, 65536)
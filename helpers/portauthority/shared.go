@@ -0,0 +1,248 @@
+package portauthority
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	stateFileName = "ports.json"
+	lockFileName  = "ports.lock"
+)
+
+// DefaultStateDir is the directory shared by every allocator created with
+// NewSharedPortAllocator on this host unless the caller picks another one.
+func DefaultStateDir() string {
+	return filepath.Join(os.TempDir(), "inigo-port-authority")
+}
+
+type sharedPortAllocator struct {
+	stateDir     string
+	startingPort uint16
+	endingPort   uint16
+	pid          int
+
+	mutex sync.Mutex
+}
+
+type sharedState struct {
+	// NextPort is where the next search for free ports begins. It wraps
+	// around to the start of the range, so released ports are reused only
+	// after the rest of the range has been handed out.
+	NextPort uint16 `json:"next_port"`
+
+	// Reservations maps each claimed port to the pid of its owner.
+	Reservations map[uint16]int `json:"reservations"`
+}
+
+// NewSharedPortAllocator creates a port allocator that coordinates with every
+// other shared allocator using the same stateDir, including those in other
+// processes such as parallel ginkgo nodes. Reservations are recorded in a
+// state file guarded by a lock file in stateDir, and reservations held by
+// processes that have exited are recycled.
+//
+// returns a non-nil error if the ending port exceeds the IANA maximum of 65535
+// or the state directory cannot be created.
+func NewSharedPortAllocator(stateDir string, startingPort, endingPort int) (PortReleaser, error) {
+	if endingPort > 65535 || startingPort < 0 || startingPort > endingPort {
+		return nil, errors.New("Invalid port range requested. Ports can only be numbers between 0-65535")
+	}
+
+	err := os.MkdirAll(stateDir, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sharedPortAllocator{
+		stateDir:     stateDir,
+		startingPort: uint16(startingPort),
+		endingPort:   uint16(endingPort),
+		pid:          os.Getpid(),
+	}, nil
+}
+
+// ClaimPorts reserves numPorts consecutive ports that nothing is listening on
+// and that no other shared allocator has reserved, and returns the first one.
+//
+// returns a non-nil error if no such range is left.
+func (p *sharedPortAllocator) ClaimPorts(numPorts int) (uint16, error) {
+	if numPorts < 1 {
+		return 0, errors.New("must claim at least one port")
+	}
+
+	var claimed uint16
+	err := p.withState(func(state *sharedState) error {
+		rangeSize := int(p.endingPort) - int(p.startingPort) + 1
+		if numPorts > rangeSize {
+			return errors.New("insufficient ports available")
+		}
+
+		start := int(state.NextPort)
+		if start < int(p.startingPort) || start > int(p.endingPort) {
+			start = int(p.startingPort)
+		}
+
+		for offset := 0; offset < rangeSize; offset++ {
+			port := int(p.startingPort) + (start-int(p.startingPort)+offset)%rangeSize
+			if port+numPorts-1 > int(p.endingPort) {
+				continue
+			}
+
+			if !p.available(state, port, numPorts) {
+				continue
+			}
+
+			for i := 0; i < numPorts; i++ {
+				state.Reservations[uint16(port+i)] = p.pid
+			}
+			state.NextPort = uint16(port + numPorts)
+			claimed = uint16(port)
+			return nil
+		}
+
+		return errors.New("insufficient ports available")
+	})
+
+	return claimed, err
+}
+
+// ReleasePorts gives back numPorts ports starting at port so that they can be
+// claimed again by any shared allocator.
+//
+// returns a non-nil error if any of the ports is not reserved by this process.
+func (p *sharedPortAllocator) ReleasePorts(port uint16, numPorts int) error {
+	if int(port)+numPorts-1 > int(p.endingPort) {
+		return fmt.Errorf("ports %d to %d are outside of the range", port, int(port)+numPorts-1)
+	}
+
+	return p.withState(func(state *sharedState) error {
+		for i := 0; i < numPorts; i++ {
+			current := port + uint16(i)
+			if owner, ok := state.Reservations[current]; !ok || owner != p.pid {
+				return fmt.Errorf("port %d was not claimed", current)
+			}
+		}
+
+		for i := 0; i < numPorts; i++ {
+			delete(state.Reservations, port+uint16(i))
+		}
+		return nil
+	})
+}
+
+func (p *sharedPortAllocator) available(state *sharedState, port, numPorts int) bool {
+	for i := 0; i < numPorts; i++ {
+		if _, reserved := state.Reservations[uint16(port+i)]; reserved {
+			return false
+		}
+	}
+
+	for i := 0; i < numPorts; i++ {
+		if !canBind(port + i) {
+			return false
+		}
+	}
+	return true
+}
+
+// withState runs f with the current state while holding the lock file, and
+// persists the state if f succeeds.
+func (p *sharedPortAllocator) withState(f func(*sharedState) error) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	lockFile, err := os.OpenFile(filepath.Join(p.stateDir, lockFileName), os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	defer lockFile.Close()
+
+	err = lockFileExclusive(lockFile)
+	if err != nil {
+		return err
+	}
+	defer unlockFile(lockFile)
+
+	state, err := p.loadState()
+	if err != nil {
+		return err
+	}
+
+	for port, owner := range state.Reservations {
+		if owner != p.pid && !processExists(owner) {
+			delete(state.Reservations, port)
+		}
+	}
+
+	err = f(state)
+	if err != nil {
+		return err
+	}
+
+	return p.saveState(state)
+}
+
+func (p *sharedPortAllocator) loadState() (*sharedState, error) {
+	state := &sharedState{Reservations: map[uint16]int{}}
+
+	data, err := os.ReadFile(filepath.Join(p.stateDir, stateFileName))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return state, nil
+	}
+
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt port allocator state: %w", err)
+	}
+	if state.Reservations == nil {
+		state.Reservations = map[uint16]int{}
+	}
+	return state, nil
+}
+
+func (p *sharedPortAllocator) saveState(state *sharedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(p.stateDir, stateFileName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	err = tmpFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), filepath.Join(p.stateDir, stateFileName))
+}
+
+func canBind(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
@@ -0,0 +1,94 @@
+package portauthority_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"code.cloudfoundry.org/inigo/helpers/portauthority"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SharedPortAllocator", func() {
+	var (
+		stateDir  string
+		startPort int
+		allocator portauthority.PortReleaser
+		err       error
+	)
+
+	BeforeEach(func() {
+		stateDir, err = os.MkdirTemp("", "port-authority")
+		Expect(err).NotTo(HaveOccurred())
+
+		startPort = 40000 + 100*GinkgoParallelProcess()
+
+		allocator, err = portauthority.NewSharedPortAllocator(stateDir, startPort, startPort+9)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(stateDir)).To(Succeed())
+	})
+
+	It("starts allocating at the beginning of the range", func() {
+		Expect(allocator.ClaimPorts(1)).To(BeEquivalentTo(startPort))
+		Expect(allocator.ClaimPorts(2)).To(BeEquivalentTo(startPort + 1))
+		Expect(allocator.ClaimPorts(1)).To(BeEquivalentTo(startPort + 3))
+	})
+
+	It("does not hand out ports claimed by another allocator sharing the state dir", func() {
+		other, err := portauthority.NewSharedPortAllocator(stateDir, startPort, startPort+9)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(allocator.ClaimPorts(2)).To(BeEquivalentTo(startPort))
+		Expect(other.ClaimPorts(2)).To(BeEquivalentTo(startPort + 2))
+		Expect(allocator.ClaimPorts(1)).To(BeEquivalentTo(startPort + 4))
+	})
+
+	It("skips ports that something is already listening on", func() {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", startPort+1))
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+
+		Expect(allocator.ClaimPorts(2)).To(BeEquivalentTo(startPort + 2))
+		Expect(allocator.ClaimPorts(1)).To(BeEquivalentTo(startPort + 4))
+	})
+
+	Context("when ports are released", func() {
+		It("recycles them once the rest of the range has been handed out", func() {
+			Expect(allocator.ClaimPorts(5)).To(BeEquivalentTo(startPort))
+			Expect(allocator.ReleasePorts(uint16(startPort), 5)).To(Succeed())
+
+			Expect(allocator.ClaimPorts(5)).To(BeEquivalentTo(startPort + 5))
+			Expect(allocator.ClaimPorts(5)).To(BeEquivalentTo(startPort))
+		})
+
+		It("errors when the ports run past the end of the range", func() {
+			Expect(allocator.ClaimPorts(10)).To(BeEquivalentTo(startPort))
+			Expect(allocator.ReleasePorts(uint16(startPort+9), 2)).To(MatchError(fmt.Sprintf("ports %d to %d are outside of the range", startPort+9, startPort+10)))
+		})
+
+		It("errors when the ports were not claimed", func() {
+			Expect(allocator.ReleasePorts(uint16(startPort), 1)).To(MatchError(fmt.Sprintf("port %d was not claimed", startPort)))
+		})
+	})
+
+	Context("when the range is exhausted", func() {
+		It("returns an error", func() {
+			Expect(allocator.ClaimPorts(10)).To(BeEquivalentTo(startPort))
+
+			port, err := allocator.ClaimPorts(1)
+			Expect(port).To(BeZero())
+			Expect(err).To(MatchError("insufficient ports available"))
+		})
+	})
+
+	Context("when a range outside the port spec is requested", func() {
+		It("errors", func() {
+			_, err = portauthority.NewSharedPortAllocator(stateDir, 30, 65536)
+			Expect(err).To(MatchError("Invalid port range requested. Ports can only be numbers between 0-65535"))
+		})
+	})
+})
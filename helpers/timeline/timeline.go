@@ -0,0 +1,324 @@
+// Package timeline merges the lager output of components and the BBS events
+// of a spec into one chronological view, to see what every component did
+// around a failure.
+package timeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/inigo/helpers/lagerlog"
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/onsi/gomega/gbytes"
+)
+
+const (
+	KindLog      = "log"
+	KindBBSEvent = "bbs-event"
+)
+
+// resubscribeInterval is how often WatchBBS tries to resubscribe to an event
+// stream that ended.
+const resubscribeInterval = 500 * time.Millisecond
+
+// Entry is a log line or BBS event on the timeline.
+type Entry struct {
+	Time      time.Time   `json:"time"`
+	Component string      `json:"component"`
+	Kind      string      `json:"kind"`
+	Level     string      `json:"level,omitempty"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// Timeline collects entries from any number of components and BBS event
+// streams. It is safe for concurrent use.
+type Timeline struct {
+	mutex   sync.Mutex
+	entries []Entry
+	sources []events.EventSource
+	stopped bool
+	done    chan struct{}
+}
+
+func New() *Timeline {
+	return &Timeline{done: make(chan struct{})}
+}
+
+// AddLog adds the lager entries in contents, logged by component. Entries
+// whose timestamp cannot be parsed are skipped. Adding the same output twice
+// adds its entries twice.
+func (t *Timeline) AddLog(component string, contents []byte) {
+	entries := []Entry{}
+	for _, logged := range lagerlog.Parse(contents) {
+		timestamp, err := parseTimestamp(logged.Timestamp)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, Entry{
+			Time:      timestamp,
+			Component: component,
+			Kind:      KindLog,
+			Level:     levelName(logged.LogLevel),
+			Message:   logged.Message,
+			Data:      logged.Data,
+		})
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.entries = append(t.entries, entries...)
+}
+
+// AddRunner adds the lager entries a runner has output so far.
+func (t *Timeline) AddRunner(component string, runner gbytes.BufferProvider) {
+	t.AddLog(component, runner.Buffer().Contents())
+}
+
+// WatchBBS adds the LRP instance and task events that mention any of guids
+// until Stop is called. The streams are resubscribed to when they end, so
+// that the timeline survives restarts of the BBS.
+func (t *Timeline) WatchBBS(logger lager.Logger, client bbs.Client, guids ...string) error {
+	subscriptions := []func(lager.Logger) (events.EventSource, error){
+		client.SubscribeToInstanceEvents,
+		client.SubscribeToTaskEvents,
+	}
+
+	for _, subscribe := range subscriptions {
+		source, err := subscribe(logger)
+		if err != nil {
+			return err
+		}
+		if !t.addSource(source) {
+			return nil
+		}
+
+		go t.watch(logger, subscribe, source, guids)
+	}
+	return nil
+}
+
+func (t *Timeline) watch(logger lager.Logger, subscribe func(lager.Logger) (events.EventSource, error), source events.EventSource, guids []string) {
+	for {
+		t.record(source, guids)
+
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-time.After(resubscribeInterval):
+			}
+
+			var err error
+			source, err = subscribe(logger)
+			if err == nil {
+				break
+			}
+		}
+
+		if !t.addSource(source) {
+			return
+		}
+	}
+}
+
+// record adds the events of source that mention any of guids, until the
+// source ends.
+func (t *Timeline) record(source events.EventSource, guids []string) {
+	for {
+		event, err := source.Next()
+		if err != nil {
+			return
+		}
+		received := time.Now()
+
+		data, err := json.Marshal(event)
+		if err != nil || !mentionsAny(string(data), guids) {
+			continue
+		}
+
+		var fields interface{}
+		if json.Unmarshal(data, &fields) != nil {
+			continue
+		}
+
+		t.mutex.Lock()
+		t.entries = append(t.entries, Entry{
+			Time:      received,
+			Component: "bbs",
+			Kind:      KindBBSEvent,
+			Message:   event.EventType(),
+			Data:      fields,
+		})
+		t.mutex.Unlock()
+	}
+}
+
+// addSource keeps source to be closed by Stop, or closes it and returns
+// false if the timeline is already stopped.
+func (t *Timeline) addSource(source events.EventSource) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stopped {
+		source.Close()
+		return false
+	}
+	t.sources = append(t.sources, source)
+	return true
+}
+
+func mentionsAny(event string, guids []string) bool {
+	for _, guid := range guids {
+		if strings.Contains(event, guid) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop closes the BBS event streams.
+func (t *Timeline) Stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stopped {
+		return
+	}
+	t.stopped = true
+	close(t.done)
+
+	for _, source := range t.sources {
+		source.Close()
+	}
+	t.sources = nil
+}
+
+// Entries returns every entry, oldest first. Entries logged at the same time
+// keep the order they were added in.
+func (t *Timeline) Entries() []Entry {
+	t.mutex.Lock()
+	entries := append([]Entry{}, t.entries...)
+	t.mutex.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
+
+// WriteText writes one line per entry:
+//
+//	2024-01-02T15:04:05.000000Z [rep-1] info rep.evacuating {"cell-id":"cell-a"}
+func (t *Timeline) WriteText(w io.Writer) error {
+	for _, entry := range t.Entries() {
+		level := entry.Level
+		if entry.Kind == KindBBSEvent {
+			level = "event"
+		}
+
+		data, err := json.Marshal(entry.Data)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "%s [%s] %s %s %s\n", entry.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"), entry.Component, level, entry.Message, data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the entries as a JSON array.
+func (t *Timeline) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t.Entries())
+}
+
+// Dump writes the timeline to timeline.txt and timeline.json in dir, and
+// returns their paths.
+func (t *Timeline) Dump(dir string) ([]string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	textPath := filepath.Join(dir, "timeline.txt")
+	err = writeFile(textPath, t.WriteText)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonPath := filepath.Join(dir, "timeline.json")
+	err = writeFile(jsonPath, t.WriteJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{textPath, jsonPath}, nil
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// parseTimestamp parses the timestamp of either lager format: RFC3339, or
+// seconds since the epoch with a fraction.
+func parseTimestamp(timestamp string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		return parsed, nil
+	}
+
+	secondsPart, fractionPart, _ := strings.Cut(timestamp, ".")
+	seconds, err := strconv.ParseInt(secondsPart, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid lager timestamp %q", timestamp)
+	}
+
+	var nanoseconds int64
+	if fractionPart != "" {
+		fractionPart = (fractionPart + "000000000")[:9]
+		nanoseconds, err = strconv.ParseInt(fractionPart, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid lager timestamp %q", timestamp)
+		}
+	}
+	return time.Unix(seconds, nanoseconds), nil
+}
+
+func levelName(level lager.LogLevel) string {
+	switch level {
+	case lager.DEBUG:
+		return "debug"
+	case lager.INFO:
+		return "info"
+	case lager.ERROR:
+		return "error"
+	case lager.FATAL:
+		return "fatal"
+	}
+	return strconv.Itoa(int(level))
+}
@@ -0,0 +1,78 @@
+package timeline_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/timeline"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timeline", func() {
+	var t *timeline.Timeline
+
+	BeforeEach(func() {
+		t = timeline.New()
+		t.AddLog("rep-1", []byte(
+			`{"timestamp":"2023-11-14T22:13:22.5Z","level":"info","source":"rep","message":"rep.evacuating","data":{"cell-id":"cell-a"}}`+"\n"+
+				"not a log line\n",
+		))
+		t.AddLog("bbs", []byte(
+			`{"timestamp":"1700000001.25","source":"bbs","message":"bbs.request.desire-lrp","log_level":1,"data":{}}`+"\n"+
+				`{"timestamp":"2023-11-14T22:13:23Z","level":"error","source":"bbs","message":"bbs.converge.failed","data":{}}`+"\n",
+		))
+	})
+
+	It("merges the entries of every component, oldest first", func() {
+		entries := t.Entries()
+		Expect(entries).To(HaveLen(3))
+
+		Expect(entries[0].Component).To(Equal("bbs"))
+		Expect(entries[0].Message).To(Equal("bbs.request.desire-lrp"))
+		Expect(entries[0].Time).To(BeTemporally("==", time.Unix(1700000001, 250000000)))
+
+		Expect(entries[1].Component).To(Equal("rep-1"))
+		Expect(entries[1].Kind).To(Equal(timeline.KindLog))
+		Expect(entries[1].Level).To(Equal("info"))
+
+		Expect(entries[2].Message).To(Equal("bbs.converge.failed"))
+		Expect(entries[2].Level).To(Equal("error"))
+	})
+
+	It("writes a line per entry", func() {
+		var text bytes.Buffer
+		Expect(t.WriteText(&text)).To(Succeed())
+		Expect(text.String()).To(Equal(
+			"2023-11-14T22:13:21.250000Z [bbs] info bbs.request.desire-lrp {}\n" +
+				"2023-11-14T22:13:22.500000Z [rep-1] info rep.evacuating {\"cell-id\":\"cell-a\"}\n" +
+				"2023-11-14T22:13:23.000000Z [bbs] error bbs.converge.failed {}\n",
+		))
+	})
+
+	It("dumps the timeline as text and JSON", func() {
+		dir, err := os.MkdirTemp("", "timeline")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		paths, err := t.Dump(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paths).To(Equal([]string{filepath.Join(dir, "timeline.txt"), filepath.Join(dir, "timeline.json")}))
+
+		contents, err := os.ReadFile(filepath.Join(dir, "timeline.json"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var entries []timeline.Entry
+		Expect(json.Unmarshal(contents, &entries)).To(Succeed())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[1].Component).To(Equal("rep-1"))
+	})
+
+	It("can be stopped more than once", func() {
+		t.Stop()
+		t.Stop()
+	})
+})
@@ -0,0 +1,140 @@
+// Package tracing follows a request through the components it reaches. A
+// Trace issues a call with a generated request id, and collects the entries
+// that each registered component logged with the matching trace id:
+//
+//	trace := tracing.New()
+//	trace.Register("bbs", bbsRunner)
+//	trace.Register("rep", cell.Rep)
+//
+//	err := trace.Issue(func(requestID string) error {
+//		return bbsClient.DesireTask(logger, requestID, guid, domain, definition)
+//	})
+//	Expect(err).NotTo(HaveOccurred())
+//
+//	trace.ExpectPropagatedTo("bbs", "rep")
+package tracing
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/lagerlog"
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// Span is an entry a component logged with the trace's id.
+type Span struct {
+	Component string
+	Timestamp string
+	Source    string
+	Message   string
+	SpanID    string
+	Data      lager.Data
+}
+
+// Trace is a request id and the components it is expected to reach.
+type Trace struct {
+	// RequestID is passed to the call as the request, or trace, id. It is a
+	// GUID, as the X-Vcap-Request-Id header of a real request would be.
+	RequestID string
+
+	// TraceID is RequestID as the components log it, without dashes.
+	TraceID string
+
+	components []string
+	runners    map[string]gbytes.BufferProvider
+}
+
+// New returns a Trace with a new request id and no components.
+func New() *Trace {
+	requestID := helpers.GenerateGuid()
+	return &Trace{
+		RequestID: requestID,
+		TraceID:   strings.ReplaceAll(requestID, "-", ""),
+		runners:   map[string]gbytes.BufferProvider{},
+	}
+}
+
+// Follow returns a Trace for an id that a component generated itself, such
+// as the ssh-proxy does for each connection, and no components.
+func Follow(traceID string) *Trace {
+	return &Trace{
+		RequestID: traceID,
+		TraceID:   strings.ReplaceAll(traceID, "-", ""),
+		runners:   map[string]gbytes.BufferProvider{},
+	}
+}
+
+// Register adds the output of a component to the trace, under name.
+// Registering a name again replaces its runner.
+func (t *Trace) Register(name string, runner gbytes.BufferProvider) {
+	if _, ok := t.runners[name]; !ok {
+		t.components = append(t.components, name)
+	}
+	t.runners[name] = runner
+}
+
+// Issue calls call with the trace's request id, usually to make a BBS
+// request such as DesireLRP or DesireTask.
+func (t *Trace) Issue(call func(requestID string) error) error {
+	return call(t.RequestID)
+}
+
+// Spans returns the spans of every registered component, in the order the
+// component logged them. Components that did not log the trace id have no
+// spans.
+func (t *Trace) Spans() map[string][]Span {
+	spans := map[string][]Span{}
+	for _, name := range t.components {
+		entries, err := lagerlog.Entries(t.runners[name])
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.Data["trace-id"] != t.TraceID {
+				continue
+			}
+
+			spanID, _ := entry.Data["span-id"].(string)
+			spans[name] = append(spans[name], Span{
+				Component: name,
+				Timestamp: entry.Timestamp,
+				Source:    entry.Source,
+				Message:   entry.Message,
+				SpanID:    spanID,
+				Data:      entry.Data,
+			})
+		}
+	}
+	return spans
+}
+
+// Missing returns the components, of those given, that have not logged the
+// trace id, in the order given. Components that were never registered are always missing.
+func (t *Trace) Missing(components ...string) []string {
+	spans := t.Spans()
+
+	missing := []string{}
+	for _, name := range components {
+		if len(spans[name]) == 0 {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// ExpectPropagatedTo waits for every given component to log the trace id.
+func (t *Trace) ExpectPropagatedTo(components ...string) {
+	for _, name := range components {
+		_, ok := t.runners[name]
+		Expect(ok).To(BeTrue(), fmt.Sprintf("component %q is not registered with the trace", name))
+	}
+
+	EventuallyWithOffset(1, func() []string {
+		return t.Missing(components...)
+	}).Should(BeEmpty(), fmt.Sprintf("trace %s did not reach every component", t.TraceID))
+}
@@ -0,0 +1,117 @@
+package tracing_test
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/inigo/helpers/tracing"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// provider hands a canned buffer to the trace, as a ginkgomon runner hands
+// over its output.
+type provider struct {
+	buffer *gbytes.Buffer
+}
+
+func (p provider) Buffer() *gbytes.Buffer {
+	return p.buffer
+}
+
+var _ = Describe("Trace", func() {
+	var (
+		trace *tracing.Trace
+		bbs   *gbytes.Buffer
+		rep   *gbytes.Buffer
+	)
+
+	line := func(timestamp, message, traceID, spanID string) string {
+		return fmt.Sprintf(
+			`{"timestamp":%q,"level":"info","source":"test","message":%q,"data":{"trace-id":%q,"span-id":%q}}`+"\n",
+			timestamp, message, traceID, spanID,
+		)
+	}
+
+	BeforeEach(func() {
+		trace = tracing.New()
+		Expect(trace.TraceID).NotTo(ContainSubstring("-"))
+
+		bbs = gbytes.BufferWithBytes([]byte(
+			line("2023-11-14T22:13:21Z", "bbs.request.desire-lrp", trace.TraceID, "span-1") +
+				line("2023-11-14T22:13:21.5Z", "bbs.request.other", "another-trace", "span-9") +
+				"not a log line\n" +
+				line("2023-11-14T22:13:22Z", "bbs.request.desire-lrp.done", trace.TraceID, "span-2"),
+		))
+		rep = gbytes.BufferWithBytes([]byte(
+			line("2023-11-14T22:13:20Z", "rep.other", "another-trace", "span-9"),
+		))
+
+		trace.Register("bbs", provider{bbs})
+		trace.Register("rep", provider{rep})
+	})
+
+	It("passes its request id to the call", func() {
+		var issued string
+		err := trace.Issue(func(requestID string) error {
+			issued = requestID
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issued).To(Equal(trace.RequestID))
+	})
+
+	It("follows an id that a component generated", func() {
+		followed := tracing.Follow("1234-abcd")
+		Expect(followed.TraceID).To(Equal("1234abcd"))
+
+		followed.Register("ssh-proxy", provider{gbytes.BufferWithBytes([]byte(
+			line("2023-11-14T22:13:23Z", "ssh-proxy.authenticate", "1234abcd", "span-1"),
+		))})
+		Expect(followed.Missing("ssh-proxy")).To(BeEmpty())
+	})
+
+	Describe("Spans", func() {
+		It("lists the entries with the trace id of each component, in the order they were logged", func() {
+			spans := trace.Spans()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans["bbs"]).To(HaveLen(2))
+
+			Expect(spans["bbs"][0].Component).To(Equal("bbs"))
+			Expect(spans["bbs"][0].Message).To(Equal("bbs.request.desire-lrp"))
+			Expect(spans["bbs"][0].SpanID).To(Equal("span-1"))
+			Expect(spans["bbs"][0].Timestamp).To(Equal("2023-11-14T22:13:21Z"))
+
+			Expect(spans["bbs"][1].Message).To(Equal("bbs.request.desire-lrp.done"))
+			Expect(spans["bbs"][1].SpanID).To(Equal("span-2"))
+		})
+
+		It("reads the buffers without moving their read cursor", func() {
+			trace.Spans()
+			Expect(bbs).To(gbytes.Say("bbs.request.desire-lrp"))
+		})
+
+		It("uses the latest runner registered under a name", func() {
+			trace.Register("rep", provider{gbytes.BufferWithBytes([]byte(
+				line("2023-11-14T22:13:23Z", "rep.claimed", trace.TraceID, "span-3"),
+			))})
+
+			spans := trace.Spans()
+			Expect(spans["rep"]).To(HaveLen(1))
+			Expect(spans["rep"][0].Message).To(Equal("rep.claimed"))
+		})
+	})
+
+	Describe("Missing", func() {
+		It("returns the components without spans, in the order given", func() {
+			Expect(trace.Missing("rep", "bbs", "garden")).To(Equal([]string{"rep", "garden"}))
+		})
+
+		It("returns nothing once every component has logged the trace id", func() {
+			_, err := rep.Write([]byte(line("2023-11-14T22:13:23Z", "rep.claimed", trace.TraceID, "span-3")))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(trace.Missing("bbs", "rep")).To(BeEmpty())
+		})
+	})
+})
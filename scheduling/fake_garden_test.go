@@ -0,0 +1,57 @@
+package scheduling_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+	"github.com/tedsuo/ifrit/grouper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fake Garden", func() {
+	var ifritRuntime ifrit.Process
+
+	BeforeEach(func() {
+		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Kill, grouper.Members{
+			{Name: "rep", Runner: componentMaker.Rep()},
+			{Name: "auctioneer", Runner: componentMaker.Auctioneer()},
+		}))
+	})
+
+	AfterEach(func() {
+		helpers.StopProcesses(ifritRuntime)
+	})
+
+	It("runs a task to completion", func() {
+		taskGuid := helpers.GenerateGuid()
+		task := helpers.TaskCreateRequest(taskGuid, &models.RunAction{
+			User: "vcap",
+			Path: "sh",
+			Args: []string{"-c", "exit 0"},
+		})
+		Expect(bbsClient.DesireTask(lgr, "", task.TaskGuid, task.Domain, task.TaskDefinition)).To(Succeed())
+
+		Eventually(helpers.TaskStatePoller(lgr, bbsClient, taskGuid, nil)).Should(Equal(models.Task_Completed))
+
+		completedTask, err := bbsClient.TaskByGuid(lgr, "", taskGuid)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(completedTask.Failed).To(BeFalse(), completedTask.FailureReason)
+	})
+
+	It("runs an LRP until it is removed", func() {
+		processGuid := helpers.GenerateGuid()
+		lrp := helpers.LightweightLRPCreateRequest(componentMaker.Addresses(), processGuid)
+		Expect(bbsClient.DesireLRP(lgr, "", lrp)).To(Succeed())
+
+		Eventually(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)).Should(Equal(models.ActualLRPStateRunning))
+		Expect(fakeGarden.Handles()).To(HaveLen(1))
+
+		Expect(bbsClient.RemoveDesiredLRP(lgr, "", processGuid)).To(Succeed())
+		Eventually(fakeGarden.Handles).Should(BeEmpty())
+	})
+})
@@ -0,0 +1 @@
+package scheduling // import "code.cloudfoundry.org/inigo/scheduling"
@@ -0,0 +1,71 @@
+package scheduling_test
+
+import (
+	"testing"
+
+	"code.cloudfoundry.org/lager/v3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/fakegarden"
+	"code.cloudfoundry.org/inigo/world"
+	"code.cloudfoundry.org/inigo/world/suite"
+)
+
+var (
+	componentMaker world.ComponentMaker
+
+	plumbing, bbsProcess, gardenProcess ifrit.Process
+	fakeGarden                          *fakegarden.Garden
+	bbsClient                           bbs.InternalClient
+	lgr                                 lager.Logger
+)
+
+var testSuite = suite.New(suite.Config{
+	Name: "scheduling",
+	Executables: []suite.Executable{
+		suite.Auctioneer,
+		suite.Rep,
+		suite.BBS,
+		suite.Locket,
+	},
+	FakeGarden: true,
+})
+
+var _ = testSuite.Register(&componentMaker)
+
+var _ = BeforeEach(func() {
+	plumbing = ginkgomon.Invoke(world.MakeCluster(componentMaker, world.Topology{
+		Components: map[string]world.ComponentSpec{
+			world.SQLComponent:    {},
+			world.NATSComponent:   {},
+			world.LocketComponent: {},
+		},
+	}).Runner())
+	fakeGarden = componentMaker.FakeGarden()
+	gardenProcess = ginkgomon.Invoke(fakeGarden)
+	bbsProcess = ginkgomon.Invoke(componentMaker.BBS())
+
+	lgr = lager.NewLogger("test")
+	lgr.RegisterSink(lager.NewWriterSink(GinkgoWriter, lager.DEBUG))
+
+	bbsClient = componentMaker.BBSClient()
+})
+
+var _ = AfterEach(func() {
+	helpers.StopProcesses(bbsProcess)
+	helpers.StopProcesses(gardenProcess)
+	helpers.StopProcesses(plumbing)
+})
+
+func TestScheduling(t *testing.T) {
+	helpers.RegisterDefaultTimeouts()
+
+	RegisterFailHandler(Fail)
+
+	RunSpecs(t, "Scheduling Integration Suite")
+}
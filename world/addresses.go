@@ -0,0 +1,131 @@
+package world
+
+import (
+	"fmt"
+	"net"
+
+	"code.cloudfoundry.org/inigo/helpers/portauthority"
+	"code.cloudfoundry.org/localip"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// repPortBlockSize is the number of consecutive ports reserved for reps. RepN
+// listens on offsetPort(port, n) and offsetPort(port+100, n), so the block
+// leaves room for maxReps reps.
+const (
+	repPortBlockSize = 200
+	maxReps          = 10
+)
+
+// checkRepIndex returns an error if the ports of the nth rep do not fit in
+// the rep port block.
+func checkRepIndex(n int) error {
+	if n < 0 || n >= maxReps {
+		return fmt.Errorf("rep %d does not fit in the rep port block, which holds reps 0 to %d", n, maxReps-1)
+	}
+	return nil
+}
+
+// MakeComponentAddresses claims a port from the allocator for every
+// component. Each claimed port is checked to be free before it is handed out.
+func MakeComponentAddresses(allocator portauthority.PortAllocator) ComponentAddresses {
+	addresses, err := NewComponentAddresses(allocator, GinkgoParallelProcess())
+	Expect(err).NotTo(HaveOccurred())
+	return addresses
+}
+
+// NewComponentAddresses is MakeComponentAddresses for callers outside of
+// ginkgo. parallelProcess keeps the SQL databases of processes sharing a
+// database server apart.
+func NewComponentAddresses(allocator portauthority.PortAllocator, parallelProcess int) (ComponentAddresses, error) {
+	_, dbBaseConnectionString := DBInfo()
+
+	localIP, err := localip.LocalIP()
+	if err != nil {
+		return ComponentAddresses{}, err
+	}
+
+	var claimErr error
+	claimPorts := func(numPorts int) uint16 {
+		if claimErr != nil {
+			return 0
+		}
+
+		var port uint16
+		port, claimErr = claimFreePorts(allocator, numPorts)
+		return port
+	}
+
+	loopbackAddress := func() string {
+		return fmt.Sprintf("127.0.0.1:%d", claimPorts(1))
+	}
+
+	addresses := ComponentAddresses{
+		Garden:              loopbackAddress(),
+		NATS:                loopbackAddress(),
+		Rep:                 fmt.Sprintf("127.0.0.1:%d", claimPorts(repPortBlockSize)),
+		FileServer:          fmt.Sprintf("%s:%d", localIP, claimPorts(1)),
+		Router:              loopbackAddress(),
+		RouterStatus:        loopbackAddress(),
+		RouterRoutes:        loopbackAddress(),
+		RouterRouteServices: loopbackAddress(),
+		BBS:                 loopbackAddress(),
+		Health:              loopbackAddress(),
+		Auctioneer:          loopbackAddress(),
+		SSHProxy:            loopbackAddress(),
+		SSHProxyHealthCheck: loopbackAddress(),
+		FakeVolmanDriver:    loopbackAddress(),
+		Locket:              loopbackAddress(),
+		SQL:                 fmt.Sprintf("%sdiego_%d", dbBaseConnectionString, parallelProcess),
+	}
+	if claimErr != nil {
+		return ComponentAddresses{}, claimErr
+	}
+
+	return addresses, nil
+}
+
+// claimFreePorts claims numPorts consecutive ports and returns the first one.
+// Ranges containing a port that something else is already listening on are
+// skipped, and released once a free range is found if the allocator is a
+// PortReleaser. They are held until then so that the allocator does not hand
+// the same busy range out again.
+func claimFreePorts(allocator portauthority.PortAllocator, numPorts int) (uint16, error) {
+	skipped := []uint16{}
+	defer func() {
+		releaser, ok := allocator.(portauthority.PortReleaser)
+		if !ok {
+			return
+		}
+
+		for _, port := range skipped {
+			// the skipped range was claimed above, so releasing it cannot fail
+			// #nosec G104
+			releaser.ReleasePorts(port, numPorts)
+		}
+	}()
+
+	for {
+		port, err := allocator.ClaimPorts(numPorts)
+		if err != nil {
+			return 0, err
+		}
+
+		if portsAreFree(port, numPorts) {
+			return port, nil
+		}
+		skipped = append(skipped, port)
+	}
+}
+
+func portsAreFree(startPort uint16, numPorts int) bool {
+	for i := 0; i < numPorts; i++ {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", int(startPort)+i))
+		if err != nil {
+			return false
+		}
+		listener.Close()
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package world
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+const auctioneerUUIDPrefix = "auctioneer-inigo-lock-owner"
+
+// auctioneerUUID is the owner name the nth auctioneer uses for the
+// auctioneer lock.
+func auctioneerUUID(n int) string {
+	if n == 0 {
+		return auctioneerUUIDPrefix
+	}
+	return auctioneerUUIDPrefix + "-" + strconv.Itoa(n)
+}
+
+// AuctioneerLockHolder returns the number of the auctioneer instance that
+// currently holds the auctioneer lock in locket.
+func (maker commonComponentFactory) AuctioneerLockHolder(logger lager.Logger) (int, error) {
+	owner, err := maker.LockOwner(logger, AuctioneerLockKey)
+	if err != nil {
+		return -1, err
+	}
+
+	if owner == auctioneerUUIDPrefix {
+		return 0, nil
+	}
+
+	if suffix := strings.TrimPrefix(owner, auctioneerUUIDPrefix+"-"); suffix != owner {
+		if n, err := strconv.Atoi(suffix); err == nil {
+			return n, nil
+		}
+	}
+
+	return -1, fmt.Errorf("auctioneer lock is held by unknown owner %q", owner)
+}
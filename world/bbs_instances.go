@@ -0,0 +1,92 @@
+package world
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// BBSInstance is where the nth BBS started by BBSN listens, and the owner
+// name it uses for the BBS lock.
+type BBSInstance struct {
+	ListenAddress string
+	HealthAddress string
+	UUID          string
+}
+
+// URL returns the address BBS clients of this instance connect to.
+func (i BBSInstance) URL() string {
+	return "https://" + i.ListenAddress
+}
+
+// bbsInstances remembers the ports claimed for each BBS instance, so that an
+// instance that is restarted comes back on the same address. It is shared by
+// all copies of the factory.
+type bbsInstances struct {
+	mutex     sync.Mutex
+	instances map[int]BBSInstance
+}
+
+func newBBSInstances() *bbsInstances {
+	return &bbsInstances{instances: map[int]BBSInstance{}}
+}
+
+// BBSInstance returns the addresses and lock owner of the nth BBS. Instance 0
+// is the BBS returned by BBS() and listens on the BBS and Health addresses;
+// the others listen on ports claimed from the port allocator.
+func (maker commonComponentFactory) BBSInstance(n int) (BBSInstance, error) {
+	if n == 0 {
+		return BBSInstance{
+			ListenAddress: maker.addresses.BBS,
+			HealthAddress: maker.addresses.Health,
+			UUID:          "bbs-inigo-lock-owner",
+		}, nil
+	}
+
+	maker.bbsInstances.mutex.Lock()
+	defer maker.bbsInstances.mutex.Unlock()
+
+	if instance, ok := maker.bbsInstances.instances[n]; ok {
+		return instance, nil
+	}
+
+	port, err := claimFreePorts(maker.portAllocator, 2)
+	if err != nil {
+		return BBSInstance{}, err
+	}
+
+	instance := BBSInstance{
+		ListenAddress: fmt.Sprintf("127.0.0.1:%d", port),
+		HealthAddress: fmt.Sprintf("127.0.0.1:%d", port+1),
+		UUID:          "bbs-inigo-lock-owner-" + strconv.Itoa(n),
+	}
+	maker.bbsInstances.instances[n] = instance
+	return instance, nil
+}
+
+// BBSLockHolder returns the number of the BBS instance that currently holds
+// the BBS lock in locket, or an error if no instance started by this factory
+// holds it.
+func (maker commonComponentFactory) BBSLockHolder(logger lager.Logger) (int, error) {
+	owner, err := maker.LockOwner(logger, BBSLockKey)
+	if err != nil {
+		return -1, err
+	}
+
+	if primary, _ := maker.BBSInstance(0); owner == primary.UUID {
+		return 0, nil
+	}
+
+	maker.bbsInstances.mutex.Lock()
+	defer maker.bbsInstances.mutex.Unlock()
+
+	for n, instance := range maker.bbsInstances.instances {
+		if instance.UUID == owner {
+			return n, nil
+		}
+	}
+
+	return -1, fmt.Errorf("bbs lock is held by unknown owner %q", owner)
+}
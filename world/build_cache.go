@@ -0,0 +1,237 @@
+package world
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/onsi/gomega/gexec"
+)
+
+// BuildCache reuses executables built by gexec.Build across runs and suites.
+// Executables are keyed by the hash of the source files of every
+// non-standard package they are built from, the build flags, the go version
+// and the build environment, so an executable is rebuilt whenever any of
+// them changes.
+//
+// A BuildCache with no directory builds every executable without caching.
+type BuildCache struct {
+	dir string
+}
+
+// NewBuildCache returns a BuildCache that stores executables under dir.
+func NewBuildCache(dir string) BuildCache {
+	return BuildCache{dir: dir}
+}
+
+// BuildCacheFromEnv returns a BuildCache that stores executables under
+// $INIGO_BUILD_CACHE_DIR, or one that does not cache if it is unset.
+func BuildCacheFromEnv() BuildCache {
+	return NewBuildCache(os.Getenv("INIGO_BUILD_CACHE_DIR"))
+}
+
+// Build is gexec.Build through the cache. The returned path is inside the
+// cache directory and must not be moved or modified.
+func (c BuildCache) Build(packagePath string, args ...string) (string, error) {
+	if c.dir == "" {
+		return gexec.Build(packagePath, args...)
+	}
+
+	key, err := buildKey(packagePath, args)
+	if err != nil {
+		return "", err
+	}
+
+	cachedPath := filepath.Join(c.dir, key, executableName(packagePath))
+	if _, err := os.Stat(cachedPath); err == nil {
+		return cachedPath, nil
+	}
+
+	builtPath, err := gexec.Build(packagePath, args...)
+	if err != nil {
+		return "", err
+	}
+
+	err = c.store(builtPath, cachedPath)
+	if err != nil {
+		return "", err
+	}
+	return cachedPath, nil
+}
+
+// BuildTo is Build followed by a copy of the executable to path, for
+// executables that are packaged or renamed after they are built.
+func (c BuildCache) BuildTo(path string, packagePath string, args ...string) error {
+	builtPath, err := c.Build(packagePath, args...)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(builtPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// store copies the executable at builtPath to cachedPath. The copy is written
+// to a temporary file first, so that concurrent suites never see a partial
+// executable.
+func (c BuildCache) store(builtPath, cachedPath string) error {
+	err := os.MkdirAll(filepath.Dir(cachedPath), 0755)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(builtPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(filepath.Dir(cachedPath), ".build-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+
+	err = dst.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(dst.Name(), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(dst.Name(), cachedPath)
+}
+
+// goPackage is the subset of `go list -json` used to hash a package.
+type goPackage struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	GoFiles    []string
+	CgoFiles   []string
+	CFiles     []string
+	HFiles     []string
+	SFiles     []string
+	EmbedFiles []string
+}
+
+func buildKey(packagePath string, args []string) (string, error) {
+	hash := sha256.New()
+
+	version, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("go version: %w", err)
+	}
+	fmt.Fprintf(hash, "%s\n%s\n%s\n", version, packagePath, strings.Join(args, " "))
+
+	for _, env := range []string{"CGO_ENABLED", "GOOS", "GOARCH", "GOFLAGS"} {
+		fmt.Fprintf(hash, "%s=%s\n", env, os.Getenv(env))
+	}
+	fmt.Fprintf(hash, "%s/%s\n", runtime.GOOS, runtime.GOARCH)
+
+	listArgs := []string{"list", "-deps", "-json"}
+	if tags := buildTags(args); tags != "" {
+		listArgs = append(listArgs, "-tags", tags)
+	}
+	listArgs = append(listArgs, packagePath)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", listArgs...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("go list %s: %w: %s", packagePath, err, stderr.String())
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for decoder.More() {
+		var pkg goPackage
+		err := decoder.Decode(&pkg)
+		if err != nil {
+			return "", err
+		}
+		if pkg.Standard {
+			continue
+		}
+
+		fmt.Fprintf(hash, "package %s\n", pkg.ImportPath)
+		files := [][]string{pkg.GoFiles, pkg.CgoFiles, pkg.CFiles, pkg.HFiles, pkg.SFiles, pkg.EmbedFiles}
+		for _, names := range files {
+			for _, name := range names {
+				err := hashFile(hash, filepath.Join(pkg.Dir, name))
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(w, "file %s\n", filepath.Base(path))
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// buildTags returns the value of the -tags flag in args, if any.
+func buildTags(args []string) string {
+	for i, arg := range args {
+		if arg == "-tags" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(arg, "-tags=") {
+			return strings.TrimPrefix(arg, "-tags=")
+		}
+	}
+	return ""
+}
+
+// executableName is the name gexec.Build gives the executable of
+// packagePath.
+func executableName(packagePath string) string {
+	name := filepath.Base(packagePath)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
@@ -0,0 +1,80 @@
+package world_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/inigo/world"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("BuildCache", func() {
+	const packagePath = "./cmd/hello"
+
+	var (
+		moduleDir string
+		cache     world.BuildCache
+	)
+
+	writeMain := func(message string) {
+		source := "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"" + message + "\") }\n"
+		Expect(os.WriteFile(filepath.Join(moduleDir, "cmd", "hello", "main.go"), []byte(source), 0644)).To(Succeed())
+	}
+
+	build := func(args ...string) string {
+		path, err := cache.Build(packagePath, args...)
+		Expect(err).NotTo(HaveOccurred())
+		return path
+	}
+
+	BeforeEach(func() {
+		moduleDir = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(moduleDir, "cmd", "hello"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(moduleDir, "go.mod"), []byte("module example.com/hello\n\ngo 1.20\n"), 0644)).To(Succeed())
+		writeMain("hello")
+
+		// the package is built and listed relative to the working directory
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(moduleDir)).To(Succeed())
+		DeferCleanup(os.Chdir, wd)
+
+		cache = world.NewBuildCache(GinkgoT().TempDir())
+		DeferCleanup(gexec.CleanupBuildArtifacts)
+	})
+
+	It("keys a package built again with the same arguments and environment the same", func() {
+		Expect(build("-tags", "inigo")).To(Equal(build("-tags", "inigo")))
+	})
+
+	It("rebuilds when the arguments change", func() {
+		Expect(build()).NotTo(Equal(build("-tags", "other")))
+	})
+
+	It("rebuilds when the build environment changes", func() {
+		path := build()
+
+		GinkgoT().Setenv("GOFLAGS", "-trimpath")
+		Expect(build()).NotTo(Equal(path))
+	})
+
+	It("rebuilds when a source file of the package changes", func() {
+		path := build()
+
+		writeMain("goodbye")
+		Expect(build()).NotTo(Equal(path))
+	})
+
+	It("copies the cached executable rather than building it again", func() {
+		path := build()
+
+		// a rebuild would overwrite the marker with a real executable
+		Expect(os.WriteFile(path, []byte("cached"), 0755)).To(Succeed())
+
+		copied := filepath.Join(GinkgoT().TempDir(), "hello")
+		Expect(cache.BuildTo(copied, packagePath)).To(Succeed())
+		Expect(os.ReadFile(copied)).To(Equal([]byte("cached")))
+	})
+})
@@ -0,0 +1,137 @@
+package world
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"code.cloudfoundry.org/bbs/models"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+	"github.com/tedsuo/ifrit/grouper"
+)
+
+// CellSpec describes a single cell of a multi-cell deployment. Capacities of
+// zero leave the rep to detect them from garden.
+type CellSpec struct {
+	Zone                  string
+	PlacementTags         []string
+	OptionalPlacementTags []string
+	MemoryMB              int
+	DiskMB                int
+}
+
+// Cells holds a rep for each CellSpec. The nth spec is started as RepN(n).
+type Cells struct {
+	Specs   []CellSpec
+	Reps    []*ginkgomon.Runner
+	CellIDs []string
+
+	members grouper.Members
+}
+
+// NewCells builds a rep for each spec. The modify funcs are applied to every
+// rep before the spec, so they cannot override its zone, tags or capacity.
+func NewCells(factory ComponentFactory, specs []CellSpec, modifyConfigFuncs ...func(*repconfig.RepConfig)) (*Cells, error) {
+	if len(specs) > 0 {
+		err := checkRepIndex(len(specs) - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cells := &Cells{Specs: specs}
+
+	for n, spec := range specs {
+		if spec.Zone == "" {
+			return nil, fmt.Errorf("cell %d has no zone", n)
+		}
+
+		var cellID string
+		funcs := append([]func(*repconfig.RepConfig){}, modifyConfigFuncs...)
+		funcs = append(funcs, func(cfg *repconfig.RepConfig) {
+			spec.apply(cfg)
+			cellID = cfg.CellID
+		})
+
+		rep, err := factory.RepN(n, funcs...)
+		if err != nil {
+			return nil, err
+		}
+
+		cells.Reps = append(cells.Reps, rep)
+		cells.CellIDs = append(cells.CellIDs, cellID)
+		cells.members = append(cells.members, grouper.Member{
+			Name:   "rep-" + strconv.Itoa(n),
+			Runner: factory.WithReadinessCheck(rep),
+		})
+	}
+
+	return cells, nil
+}
+
+// MakeCells is NewCells for use in specs.
+func MakeCells(maker ComponentMaker, specs []CellSpec, modifyConfigFuncs ...func(*repconfig.RepConfig)) *Cells {
+	cells, err := NewCells(maker.Factory(), specs, modifyConfigFuncs...)
+	Expect(err).NotTo(HaveOccurred())
+	return cells
+}
+
+func (spec CellSpec) apply(cfg *repconfig.RepConfig) {
+	cfg.Zone = spec.Zone
+	cfg.PlacementTags = spec.PlacementTags
+	cfg.OptionalPlacementTags = spec.OptionalPlacementTags
+	if spec.MemoryMB > 0 {
+		cfg.ExecutorConfig.MemoryMB = strconv.Itoa(spec.MemoryMB)
+	}
+	if spec.DiskMB > 0 {
+		cfg.ExecutorConfig.DiskMB = strconv.Itoa(spec.DiskMB)
+	}
+}
+
+// Runner returns an ifrit.Runner that starts every rep in parallel.
+func (c *Cells) Runner() ifrit.Runner {
+	return grouper.NewParallel(os.Kill, c.members)
+}
+
+// Zones returns the distinct zones of the cells, sorted.
+func (c *Cells) Zones() []string {
+	seen := map[string]bool{}
+	zones := []string{}
+	for _, spec := range c.Specs {
+		if !seen[spec.Zone] {
+			seen[spec.Zone] = true
+			zones = append(zones, spec.Zone)
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+// ZoneOf returns the zone of the cell with the given ID, or "" if the cell is
+// not one of these cells.
+func (c *Cells) ZoneOf(cellID string) string {
+	for n, id := range c.CellIDs {
+		if id == cellID {
+			return c.Specs[n].Zone
+		}
+	}
+	return ""
+}
+
+// ZoneDistribution counts the given ActualLRPs in each zone. Every zone of
+// the cells is present, and ActualLRPs that are not placed on one of these
+// cells are counted under "".
+func (c *Cells) ZoneDistribution(lrps []models.ActualLRP) map[string]int {
+	distribution := map[string]int{}
+	for _, zone := range c.Zones() {
+		distribution[zone] = 0
+	}
+	for _, lrp := range lrps {
+		distribution[c.ZoneOf(lrp.CellId)]++
+	}
+	return distribution
+}
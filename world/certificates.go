@@ -0,0 +1,172 @@
+package world
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"code.cloudfoundry.org/inigo/helpers/certauthority"
+)
+
+// certificates are the CA bundle and the server and client certificates that
+// every SSLConfig of a factory points at. It is shared by all copies of the
+// factory, and rotating it rewrites the files in place, so that every
+// component restarted afterwards picks up the rotated certificates.
+type certificates struct {
+	mutex       sync.Mutex
+	dir         string
+	bundlePath  string
+	authorities []certauthority.CertAuthority
+	original    certauthority.CertAuthority
+	issued      []issuedCertificate
+}
+
+type issuedCertificate struct {
+	commonName string
+	sans       []string
+	keyPath    string
+	certPath   string
+	key        []byte
+	cert       []byte
+}
+
+func newCertificates(dir string, authority certauthority.CertAuthority) (*certificates, error) {
+	c := &certificates{
+		dir:         dir,
+		bundlePath:  filepath.Join(dir, "ca-bundle.crt"),
+		authorities: []certauthority.CertAuthority{authority},
+		original:    authority,
+	}
+
+	err := certauthority.WriteCABundle(c.bundlePath, c.authorities...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// issue signs a certificate with the newest CA, to be reissued by every
+// rotation.
+func (c *certificates) issue(commonName string, sans []string) (string, string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key, cert, err := c.authorities[len(c.authorities)-1].GenerateSelfSignedCertAndKey(commonName, sans, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	keyBytes, err := os.ReadFile(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	certBytes, err := os.ReadFile(cert)
+	if err != nil {
+		return "", "", err
+	}
+
+	c.issued = append(c.issued, issuedCertificate{
+		commonName: commonName,
+		sans:       sans,
+		keyPath:    key,
+		certPath:   cert,
+		key:        keyBytes,
+		cert:       certBytes,
+	})
+	return key, cert, nil
+}
+
+// RotateCA issues a new CA named commonName and adds it to the CA bundle of
+// every SSLConfig, next to the CAs already trusted. A CA rotation restarts
+// every component after each of its three steps:
+//
+//	RotateCA, so that every component trusts the new CA,
+//	ReissueCertificates, so that every component presents certificates
+//	signed by it,
+//	RetireOldCAs, so that no component trusts the old CA any longer.
+//
+// Each step also reconnects the factory's own locket client, so that it uses
+// the certificates the step leaves on disk.
+func (maker commonComponentFactory) RotateCA(commonName string) error {
+	c := maker.certificates
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	depotDir, err := NewTempDirWithParent(c.dir, "ca-"+strconv.Itoa(len(c.authorities)+1))
+	if err != nil {
+		return err
+	}
+
+	authority, err := certauthority.NewCertAuthority(depotDir, commonName)
+	if err != nil {
+		return err
+	}
+
+	c.authorities = append(c.authorities, authority)
+	err = certauthority.WriteCABundle(c.bundlePath, c.authorities...)
+	if err != nil {
+		return err
+	}
+	return maker.locketClient.close()
+}
+
+// ReissueCertificates signs every server and client certificate of the
+// SSLConfigs again with the newest CA, at the same paths.
+func (maker commonComponentFactory) ReissueCertificates() error {
+	c := maker.certificates
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	authority := c.authorities[len(c.authorities)-1]
+	for _, issued := range c.issued {
+		err := certauthority.Reissue(authority, issued.commonName, issued.sans, issued.keyPath, issued.certPath)
+		if err != nil {
+			return err
+		}
+	}
+	return maker.locketClient.close()
+}
+
+// RetireOldCAs removes every CA but the newest from the CA bundle.
+func (maker commonComponentFactory) RetireOldCAs() error {
+	c := maker.certificates
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.authorities) < 2 {
+		return errors.New("no CA has been rotated")
+	}
+
+	c.authorities = c.authorities[len(c.authorities)-1:]
+	err := certauthority.WriteCABundle(c.bundlePath, c.authorities...)
+	if err != nil {
+		return err
+	}
+	return maker.locketClient.close()
+}
+
+// RestoreCertificates undoes every rotation: the CA bundle trusts the
+// original CA alone again, and every certificate and key is written back as
+// it was first issued.
+func (maker commonComponentFactory) RestoreCertificates() error {
+	c := maker.certificates
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.authorities = []certauthority.CertAuthority{c.original}
+	err := certauthority.WriteCABundle(c.bundlePath, c.authorities...)
+	if err != nil {
+		return err
+	}
+
+	for _, issued := range c.issued {
+		err := certauthority.WriteKeyAndCert(issued.keyPath, issued.certPath, issued.key, issued.cert)
+		if err != nil {
+			return err
+		}
+	}
+	return maker.locketClient.close()
+}
@@ -0,0 +1,127 @@
+package world
+
+import (
+	"fmt"
+	"strconv"
+
+	auctioneerconfig "code.cloudfoundry.org/auctioneer/cmd/auctioneer/config"
+	bbsconfig "code.cloudfoundry.org/bbs/cmd/bbs/config"
+	"code.cloudfoundry.org/inigo/helpers/chaos"
+	locketconfig "code.cloudfoundry.org/locket/cmd/locket/config"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	routeemitterconfig "code.cloudfoundry.org/route-emitter/cmd/route-emitter/config"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+// chaosComponents are the components a chaos.Driver may kill and restart, in
+// the order they are started.
+var chaosComponents = []string{
+	LocketComponent,
+	BBSComponent,
+	AuctioneerComponent,
+	RepComponent,
+	RouteEmitterComponent,
+}
+
+// NewChaosTargets returns a chaos.Target for every instance of the components
+// of the topology, named as the members of a Cluster are ("rep-0", "bbs", ...)
+// and in the order a Cluster starts them. Every restart builds a new runner
+// with the topology's overrides. The components the targets depend on, such
+// as sql and nats, are left to a Cluster of their own:
+//
+//	plumbing := ginkgomon.Invoke(world.MakeCluster(componentMaker, plumbingTopology).Runner())
+//	driver := chaos.New(chaos.Config{
+//		Seed:    GinkgoRandomSeed(),
+//		Targets: world.MakeChaosTargets(componentMaker, chaosTopology),
+//	})
+func NewChaosTargets(factory ComponentFactory, topology Topology) ([]chaos.Target, error) {
+	err := topology.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = topology.validateOverrides()
+	if err != nil {
+		return nil, err
+	}
+
+	chaotic := map[string]bool{}
+	for _, name := range chaosComponents {
+		chaotic[name] = true
+	}
+	for _, name := range topology.componentNames() {
+		if !chaotic[name] {
+			return nil, fmt.Errorf("component %q cannot be restarted by chaos", name)
+		}
+	}
+
+	targets := []chaos.Target{}
+	for _, name := range chaosComponents {
+		overrides := topology.Components[name].Overrides
+
+		for i := 0; i < topology.count(name); i++ {
+			targetName := name
+			if scalableComponents[name] {
+				targetName += "-" + strconv.Itoa(i)
+			}
+
+			newRunner := chaosRunner(factory, name, i, overrides)
+			targets = append(targets, chaos.Target{
+				Name: targetName,
+				NewRunner: func() (ifrit.Runner, error) {
+					runner, err := newRunner()
+					if err != nil {
+						return nil, err
+					}
+					return factory.WithReadinessCheck(runner), nil
+				},
+			})
+		}
+	}
+	return targets, nil
+}
+
+// MakeChaosTargets is NewChaosTargets for use in specs.
+func MakeChaosTargets(maker ComponentMaker, topology Topology) []chaos.Target {
+	targets, err := NewChaosTargets(maker.Factory(), topology)
+	Expect(err).NotTo(HaveOccurred())
+	return targets
+}
+
+// chaosRunner returns a func building a runner for the i-th instance of the
+// named component.
+func chaosRunner(factory ComponentFactory, name string, i int, overrides map[string]interface{}) func() (ifrit.Runner, error) {
+	switch name {
+	case LocketComponent:
+		return func() (ifrit.Runner, error) {
+			return factory.Locket(func(cfg *locketconfig.LocketConfig) {
+				applyOverrides(overrides, cfg)
+			})
+		}
+	case BBSComponent:
+		return func() (ifrit.Runner, error) {
+			return factory.BBS(func(cfg *bbsconfig.BBSConfig) {
+				applyOverrides(overrides, cfg)
+			})
+		}
+	case AuctioneerComponent:
+		return func() (ifrit.Runner, error) {
+			return factory.Auctioneer(func(cfg *auctioneerconfig.AuctioneerConfig) {
+				applyOverrides(overrides, cfg)
+			})
+		}
+	case RepComponent:
+		return func() (ifrit.Runner, error) {
+			return factory.RepN(i, func(cfg *repconfig.RepConfig) {
+				applyOverrides(overrides, cfg)
+			})
+		}
+	default:
+		return func() (ifrit.Runner, error) {
+			return factory.RouteEmitterN(i, func(cfg *routeemitterconfig.RouteEmitterConfig) {
+				applyOverrides(overrides, cfg)
+			})
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package world
+
+import (
+	"os"
+	"time"
+
+	auctioneerconfig "code.cloudfoundry.org/auctioneer/cmd/auctioneer/config"
+	"code.cloudfoundry.org/bbs"
+	bbsconfig "code.cloudfoundry.org/bbs/cmd/bbs/config"
+	"code.cloudfoundry.org/bbs/serviceclient"
+	sshproxyconfig "code.cloudfoundry.org/diego-ssh/cmd/ssh-proxy/config"
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gqt/runner"
+	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/fakegarden"
+	"code.cloudfoundry.org/inigo/helpers/faultproxy"
+	"code.cloudfoundry.org/inigo/helpers/portauthority"
+	"code.cloudfoundry.org/lager/v3"
+	locketconfig "code.cloudfoundry.org/locket/cmd/locket/config"
+	"code.cloudfoundry.org/rep"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	routeemitterconfig "code.cloudfoundry.org/route-emitter/cmd/route-emitter/config"
+	routingapi "code.cloudfoundry.org/route-emitter/cmd/route-emitter/runners"
+	"code.cloudfoundry.org/volman"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+// ComponentMaker is the ComponentFactory API for use in ginkgo specs: every
+// error fails the current spec instead of being returned.
+type ComponentMaker interface {
+	VolmanDriverConfigDir() string
+	SSHConfig() SSHKeys
+	Artifacts() BuiltArtifacts
+	PortAllocator() portauthority.PortAllocator
+	Addresses() ComponentAddresses
+	Configs(since time.Time) []ComponentConfig
+	ClearConfigs()
+	Runners(since time.Time) []ComponentRunner
+	ClearRunners()
+	Auctioneer(modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) *ginkgomon.Runner
+	AuctioneerN(n int, modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) *ginkgomon.Runner
+	AuctioneerLockHolder(logger lager.Logger) (int, error)
+	BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) *ginkgomon.Runner
+	BBSN(n int, modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) *ginkgomon.Runner
+	BBSInstance(n int) BBSInstance
+	BBSLockHolder(logger lager.Logger) (int, error)
+	BBSClient() bbs.InternalClient
+	RepClientFactory() rep.ClientFactory
+	BBSServiceClient(logger lager.Logger) serviceclient.ServiceClient
+	BBSURL() string
+	BBSSSLConfig() SSLConfig
+	DefaultStack() string
+	FakeGarden() *fakegarden.Garden
+	FaultProxy(target string) *faultproxy.Proxy
+	FileServer() (ifrit.Runner, string)
+	Garden(fs ...func(*runner.GdnRunnerConfig)) *runner.GardenRunner
+	GardenClient() garden.Client
+	GardenWithoutDefaultStack() ifrit.Runner
+	GrootFSDeleteStore()
+	GrootFSInitStore()
+	Locket(modifyConfigFuncs ...func(*locketconfig.LocketConfig)) ifrit.Runner
+	LockOwner(logger lager.Logger, key string) (string, error)
+	NATS(argv ...string) ifrit.Runner
+	Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner
+	RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner
+	RepSSLConfig() SSLConfig
+	ReissueCertificates()
+	RestoreCertificates()
+	RetireOldCAs()
+	RotateCA(commonName string)
+	RouteEmitter(fs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner
+	RouteEmitterN(n int, fs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner
+	Router() *ginkgomon.Runner
+	RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) *routingapi.RoutingAPIRunner
+	SQL(argv ...string) ifrit.Runner
+	SSHProxy(modifyConfigFuncs ...func(*sshproxyconfig.SSHProxyConfig)) ifrit.Runner
+	Setup()
+	Teardown()
+	VolmanClient(logger lager.Logger) (volman.Manager, ifrit.Runner)
+	VolmanDriver(logger lager.Logger) (ifrit.Runner, dockerdriver.Driver)
+	WithReadinessCheck(runner ifrit.Runner) ifrit.Runner
+	WithReadiness(mode ReadinessMode) ComponentMaker
+
+	// Factory returns the error-returning API the maker wraps.
+	Factory() ComponentFactory
+}
+
+func MakeV0ComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority) ComponentMaker {
+	factory, err := NewV0ComponentFactory(builtArtifacts, worldAddresses, allocator, certAuthority, ginkgoFactoryOptions())
+	Expect(err).NotTo(HaveOccurred())
+	return WrapComponentFactory(factory)
+}
+
+func MakeComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority) ComponentMaker {
+	factory, err := NewComponentFactory(builtArtifacts, worldAddresses, allocator, certAuthority, ginkgoFactoryOptions())
+	Expect(err).NotTo(HaveOccurred())
+	return WrapComponentFactory(factory)
+}
+
+// WrapComponentFactory returns a ComponentMaker that fails the current spec
+// whenever factory returns an error.
+func WrapComponentFactory(factory ComponentFactory) ComponentMaker {
+	return componentMaker{ComponentFactory: factory}
+}
+
+func ginkgoFactoryOptions() FactoryOptions {
+	return FactoryOptions{
+		ParallelProcess: GinkgoParallelProcess(),
+		Output:          GinkgoWriter,
+	}
+}
+
+func (blc *BuiltLifecycles) BuildLifecycles(lifeCycle string, tmpDir string) {
+	err := blc.Build(BuildCacheFromEnv(), lifeCycle, tmpDir, GinkgoWriter)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+type componentMaker struct {
+	ComponentFactory
+}
+
+func (maker componentMaker) Factory() ComponentFactory {
+	return maker.ComponentFactory
+}
+
+func (maker componentMaker) WithReadiness(mode ReadinessMode) ComponentMaker {
+	return componentMaker{ComponentFactory: maker.ComponentFactory.WithReadiness(mode)}
+}
+
+func (maker componentMaker) Setup() {
+	Expect(maker.ComponentFactory.Setup()).To(Succeed())
+}
+
+func (maker componentMaker) Teardown() {
+	Expect(maker.ComponentFactory.Teardown()).To(Succeed())
+}
+
+func (maker componentMaker) GrootFSInitStore() {
+	Expect(maker.ComponentFactory.GrootFSInitStore()).To(Succeed())
+}
+
+func (maker componentMaker) GrootFSDeleteStore() {
+	Expect(maker.ComponentFactory.GrootFSDeleteStore()).To(Succeed())
+}
+
+func (maker componentMaker) NATS(argv ...string) ifrit.Runner {
+	runner, err := maker.ComponentFactory.NATS(argv...)
+	Expect(err).NotTo(HaveOccurred())
+	return runner
+}
+
+func (maker componentMaker) SQL(argv ...string) ifrit.Runner {
+	sqlRunner := maker.ComponentFactory.SQL(argv...)
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		defer GinkgoRecover()
+
+		Expect(sqlRunner.Run(signals, ready)).To(Succeed())
+		return nil
+	})
+}
+
+func (maker componentMaker) DefaultStack() string {
+	stack := maker.ComponentFactory.DefaultStack()
+	Expect(stack).NotTo(BeEmpty())
+	return stack
+}
+
+func (maker componentMaker) Garden(fs ...func(*runner.GdnRunnerConfig)) *runner.GardenRunner {
+	gardenRunner, err := maker.ComponentFactory.Garden(fs...)
+	Expect(err).NotTo(HaveOccurred())
+	return gardenRunner
+}
+
+func (maker componentMaker) GardenWithoutDefaultStack() ifrit.Runner {
+	gardenRunner, err := maker.ComponentFactory.GardenWithoutDefaultStack()
+	Expect(err).NotTo(HaveOccurred())
+	return gardenRunner
+}
+
+func (maker componentMaker) RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) *routingapi.RoutingAPIRunner {
+	routingAPIRunner, err := maker.ComponentFactory.RoutingAPI(modifyConfigFuncs...)
+	Expect(err).NotTo(HaveOccurred())
+	return routingAPIRunner
+}
+
+func (maker componentMaker) RotateCA(commonName string) {
+	err := maker.ComponentFactory.RotateCA(commonName)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func (maker componentMaker) ReissueCertificates() {
+	err := maker.ComponentFactory.ReissueCertificates()
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func (maker componentMaker) RestoreCertificates() {
+	err := maker.ComponentFactory.RestoreCertificates()
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func (maker componentMaker) RetireOldCAs() {
+	err := maker.ComponentFactory.RetireOldCAs()
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func (maker componentMaker) FaultProxy(target string) *faultproxy.Proxy {
+	proxy, err := maker.ComponentFactory.FaultProxy(target)
+	Expect(err).NotTo(HaveOccurred())
+	return proxy
+}
+
+func (maker componentMaker) FileServer() (ifrit.Runner, string) {
+	fileServerRunner, servedFilesDir, err := maker.ComponentFactory.FileServer()
+	Expect(err).NotTo(HaveOccurred())
+	return fileServerRunner, servedFilesDir
+}
+
+func (maker componentMaker) Locket(modifyConfigFuncs ...func(*locketconfig.LocketConfig)) ifrit.Runner {
+	locketRunner, err := maker.ComponentFactory.Locket(modifyConfigFuncs...)
+	Expect(err).NotTo(HaveOccurred())
+	return locketRunner
+}
+
+func (maker componentMaker) Router() *ginkgomon.Runner {
+	routerRunner, err := maker.ComponentFactory.Router()
+	Expect(err).NotTo(HaveOccurred())
+	return routerRunner
+}
+
+func (maker componentMaker) SSHProxy(modifyConfigFuncs ...func(*sshproxyconfig.SSHProxyConfig)) ifrit.Runner {
+	sshProxyRunner, err := maker.ComponentFactory.SSHProxy(modifyConfigFuncs...)
+	Expect(err).NotTo(HaveOccurred())
+	return sshProxyRunner
+}
+
+func (maker componentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) *ginkgomon.Runner {
+	return maker.BBSN(0, modifyConfigFuncs...)
+}
+
+func (maker componentMaker) BBSN(n int, modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) *ginkgomon.Runner {
+	bbsRunner, err := maker.ComponentFactory.BBSN(n, modifyConfigFuncs...)
+	Expect(err).NotTo(HaveOccurred())
+	return bbsRunner
+}
+
+func (maker componentMaker) BBSInstance(n int) BBSInstance {
+	instance, err := maker.ComponentFactory.BBSInstance(n)
+	Expect(err).NotTo(HaveOccurred())
+	return instance
+}
+
+func (maker componentMaker) Auctioneer(modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) *ginkgomon.Runner {
+	return maker.AuctioneerN(0, modifyConfigFuncs...)
+}
+
+func (maker componentMaker) AuctioneerN(n int, modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) *ginkgomon.Runner {
+	auctioneerRunner, err := maker.ComponentFactory.AuctioneerN(n, modifyConfigFuncs...)
+	Expect(err).NotTo(HaveOccurred())
+	return auctioneerRunner
+}
+
+func (maker componentMaker) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+	return maker.RepN(0, modifyConfigFuncs...)
+}
+
+func (maker componentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+	repRunner, err := maker.ComponentFactory.RepN(n, modifyConfigFuncs...)
+	Expect(err).NotTo(HaveOccurred())
+	return repRunner
+}
+
+func (maker componentMaker) RouteEmitter(fs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner {
+	routeEmitterRunner, err := maker.ComponentFactory.RouteEmitter(fs...)
+	Expect(err).NotTo(HaveOccurred())
+	return routeEmitterRunner
+}
+
+func (maker componentMaker) RouteEmitterN(n int, fs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner {
+	routeEmitterRunner, err := maker.ComponentFactory.RouteEmitterN(n, fs...)
+	Expect(err).NotTo(HaveOccurred())
+	return routeEmitterRunner
+}
+
+func (maker componentMaker) BBSClient() bbs.InternalClient {
+	client, err := maker.ComponentFactory.BBSClient()
+	Expect(err).NotTo(HaveOccurred())
+	return client
+}
+
+func (maker componentMaker) RepClientFactory() rep.ClientFactory {
+	clientFactory, err := maker.ComponentFactory.RepClientFactory()
+	Expect(err).NotTo(HaveOccurred())
+	return clientFactory
+}
+
+func (maker componentMaker) BBSServiceClient(logger lager.Logger) serviceclient.ServiceClient {
+	serviceClient, err := maker.ComponentFactory.BBSServiceClient(logger)
+	Expect(err).NotTo(HaveOccurred())
+	return serviceClient
+}
+
+func (maker componentMaker) VolmanClient(logger lager.Logger) (volman.Manager, ifrit.Runner) {
+	manager, volmanRunner, err := maker.ComponentFactory.VolmanClient(logger)
+	Expect(err).NotTo(HaveOccurred())
+	return manager, volmanRunner
+}
+
+func (maker componentMaker) VolmanDriver(logger lager.Logger) (ifrit.Runner, dockerdriver.Driver) {
+	driverRunner, driver, err := maker.ComponentFactory.VolmanDriver(logger)
+	Expect(err).NotTo(HaveOccurred())
+	return driverRunner, driver
+}
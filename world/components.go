@@ -2,7 +2,10 @@ package world
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
@@ -38,13 +41,13 @@ import (
 	gardenconnection "code.cloudfoundry.org/garden/client/connection"
 	"code.cloudfoundry.org/guardian/gqt/runner"
 	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/fakegarden"
+	"code.cloudfoundry.org/inigo/helpers/faultproxy"
 	"code.cloudfoundry.org/inigo/helpers/portauthority"
 	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/lager/v3/lagerflags"
-	"code.cloudfoundry.org/lager/v3/lagertest"
 	"code.cloudfoundry.org/locket"
 	locketconfig "code.cloudfoundry.org/locket/cmd/locket/config"
-	locketrunner "code.cloudfoundry.org/locket/cmd/locket/testrunner"
 	"code.cloudfoundry.org/rep"
 	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
 	routeemitterconfig "code.cloudfoundry.org/route-emitter/cmd/route-emitter/config"
@@ -52,10 +55,8 @@ import (
 	routingapiconfig "code.cloudfoundry.org/routing-api/config"
 	"code.cloudfoundry.org/volman"
 	volmanclient "code.cloudfoundry.org/volman/vollocal"
+	natsserver "github.com/nats-io/nats-server/v2/server"
 	uuid "github.com/nu7hatch/gouuid"
-	. "github.com/onsi/ginkgo/v2"
-	. "github.com/onsi/gomega"
-	"github.com/onsi/gomega/gexec"
 	"github.com/tedsuo/ifrit"
 	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
 	"golang.org/x/crypto/ssh"
@@ -171,15 +172,80 @@ func DBInfo() (string, string) {
 	return dbDriverName, dbBaseConnectionString
 }
 
-func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority) commonComponentMaker {
+// FactoryOptions provide what a ComponentFactory would otherwise take from
+// the running ginkgo suite.
+type FactoryOptions struct {
+	// ParallelProcess keeps databases, cell IDs and storage paths of
+	// processes sharing a host apart. Defaults to 1.
+	ParallelProcess int
+
+	// Output receives the output of the helper commands run by the factory.
+	// Defaults to os.Stdout.
+	Output io.Writer
+
+	// Readiness selects how WithReadinessCheck decides that a component has
+	// started. Defaults to $START_CHECK_MODE, or LogReadiness if that is unset.
+	Readiness ReadinessMode
+
+	// TempDir is the directory the factory makes its temporary directory in.
+	// Defaults to os.TempDir().
+	TempDir string
+
+	// FakeGarden is set for factories whose Garden is served by FakeGarden.
+	// They need neither the Garden and GrootFS binaries nor the rootfs, and
+	// Setup and Teardown leave the GrootFS stores alone, so that they run
+	// without root.
+	FakeGarden bool
+}
+
+// NewComponentFactory returns a ComponentFactory that launches components with
+// JSON config files.
+func NewComponentFactory(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority, options FactoryOptions) (ComponentFactory, error) {
+	common, err := newCommonComponentFactory(builtArtifacts, worldAddresses, allocator, certAuthority, options)
+	if err != nil {
+		return nil, err
+	}
+	return v1ComponentFactory{commonComponentFactory: common}, nil
+}
+
+// NewV0ComponentFactory returns a ComponentFactory that launches components
+// with legacy command line flags.
+func NewV0ComponentFactory(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority, options FactoryOptions) (ComponentFactory, error) {
+	common, err := newCommonComponentFactory(builtArtifacts, worldAddresses, allocator, certAuthority, options)
+	if err != nil {
+		return nil, err
+	}
+	return v0ComponentFactory{commonComponentFactory: common}, nil
+}
+
+func newCommonComponentFactory(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority, options FactoryOptions) (commonComponentFactory, error) {
+	if options.ParallelProcess == 0 {
+		options.ParallelProcess = 1
+	}
+	if options.Output == nil {
+		options.Output = os.Stdout
+	}
+	if options.TempDir == "" {
+		options.TempDir = os.TempDir()
+	}
+
 	startCheckTimeout := 10 * time.Second
 	if timeout, found := os.LookupEnv("START_CHECK_TIMEOUT_DURATION"); found && timeout != "" {
 		var err error
 		startCheckTimeout, err = time.ParseDuration(timeout)
-		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("%s not a valid duration", timeout))
+		if err != nil {
+			return commonComponentFactory{}, fmt.Errorf("%s not a valid duration", timeout)
+		}
 	}
 
-	tmpDir := TempDir("component-maker")
+	readiness := options.Readiness
+	if readiness == "" {
+		var err error
+		readiness, err = parseReadinessMode(os.Getenv("START_CHECK_MODE"))
+		if err != nil {
+			return commonComponentFactory{}, err
+		}
+	}
 
 	grootfsBinPath := os.Getenv("GROOTFS_BINPATH")
 	grootfsStorePath := os.Getenv("GROOTFS_STORE_PATH")
@@ -187,20 +253,43 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 	gardenRootFSPath := os.Getenv("GARDEN_TEST_ROOTFS")
 	gardenGraphPath := os.Getenv("GARDEN_GRAPH_PATH")
 
-	if gardenGraphPath == "" {
-		gardenGraphPath = TempDirWithParent(tmpDir, "garden-graph")
-	}
-
-	Expect(grootfsBinPath).NotTo(BeEmpty(), "must provide $GROOTFS_BINPATH")
-	if runtime.GOOS == "windows" {
-		Expect(grootfsStorePath).NotTo(BeEmpty(), "must provide $GROOTFS_STORE_PATH")
+	if options.FakeGarden {
+		if gardenRootFSPath == "" {
+			// the fake garden never reads the rootfs of a container
+			gardenRootFSPath = "/fake-garden/rootfs"
+		}
+	} else {
+		if grootfsBinPath == "" {
+			return commonComponentFactory{}, errors.New("must provide $GROOTFS_BINPATH")
+		}
+		if runtime.GOOS == "windows" && grootfsStorePath == "" {
+			return commonComponentFactory{}, errors.New("must provide $GROOTFS_STORE_PATH")
+		}
+		if gardenBinPath == "" {
+			return commonComponentFactory{}, errors.New("must provide $GARDEN_BINPATH")
+		}
+		if gardenRootFSPath == "" {
+			return commonComponentFactory{}, errors.New("must provide $GARDEN_TEST_ROOTFS")
+		}
 	}
-	Expect(gardenBinPath).NotTo(BeEmpty(), "must provide $GARDEN_BINPATH")
-	Expect(gardenRootFSPath).NotTo(BeEmpty(), "must provide $GARDEN_TEST_ROOTFS")
 
 	// tests depend on this env var to be set
 	externalAddress := os.Getenv("EXTERNAL_ADDRESS")
-	Expect(externalAddress).NotTo(BeEmpty(), "must provide $EXTERNAL_ADDRESS")
+	if externalAddress == "" {
+		return commonComponentFactory{}, errors.New("must provide $EXTERNAL_ADDRESS")
+	}
+
+	tmpDir, err := NewTempDirWithParent(options.TempDir, "component-maker")
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
+
+	if gardenGraphPath == "" {
+		gardenGraphPath, err = NewTempDirWithParent(tmpDir, "garden-graph")
+		if err != nil {
+			return commonComponentFactory{}, err
+		}
+	}
 
 	stackPathMap := make(repconfig.RootFSes, len(PreloadedStacks))
 	for i, stack := range PreloadedStacks {
@@ -211,10 +300,14 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 	}
 
 	hostKeyPair, err := keys.RSAKeyPairFactory.NewKeyPair(1024)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
 
 	userKeyPair, err := keys.RSAKeyPairFactory.NewKeyPair(1024)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
 
 	sshKeys := SSHKeys{
 		HostKey:       hostKeyPair.PrivateKey(),
@@ -223,17 +316,36 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 		AuthorizedKey: userKeyPair.AuthorizedKey(),
 	}
 
-	_, caCert := certAuthority.CAAndKey()
-	bbsServerKey, bbsServerCert, err := certAuthority.GenerateSelfSignedCertAndKey("bbs_server", []string{"bbs_server"}, false)
-	Expect(err).NotTo(HaveOccurred())
-	repServerKey, repServerCert, err := certAuthority.GenerateSelfSignedCertAndKey("rep_server", []string{"cell.service.cf.internal", "*.cell.service.cf.internal"}, false)
-	Expect(err).NotTo(HaveOccurred())
-	auctioneerServerKey, auctioneerServerCert, err := certAuthority.GenerateSelfSignedCertAndKey("auctioneer_server", []string{"auctioneer_server"}, false)
-	Expect(err).NotTo(HaveOccurred())
-	routingAPIKey, routingAPICert, err := certAuthority.GenerateSelfSignedCertAndKey("routing_api_server", []string{"routing_api_server"}, false)
-	Expect(err).NotTo(HaveOccurred())
-	clientKey, clientCert, err := certAuthority.GenerateSelfSignedCertAndKey("client", []string{"client"}, false)
-	Expect(err).NotTo(HaveOccurred())
+	certificatesDir, err := NewTempDirWithParent(tmpDir, "certificates")
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
+	certificates, err := newCertificates(certificatesDir, certAuthority)
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
+
+	caCert := certificates.bundlePath
+	bbsServerKey, bbsServerCert, err := certificates.issue("bbs_server", []string{"bbs_server"})
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
+	repServerKey, repServerCert, err := certificates.issue("rep_server", []string{"cell.service.cf.internal", "*.cell.service.cf.internal"})
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
+	auctioneerServerKey, auctioneerServerCert, err := certificates.issue("auctioneer_server", []string{"auctioneer_server"})
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
+	routingAPIKey, routingAPICert, err := certificates.issue("routing_api_server", []string{"routing_api_server"})
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
+	clientKey, clientCert, err := certificates.issue("client", []string{"client"})
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
 
 	sqlCACert := filepath.Join("..", "fixtures", "certs", "sql-certs", "server-ca.crt")
 
@@ -278,7 +390,7 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 	storeTimestamp := time.Now().UnixNano()
 
 	unprivilegedGrootfsConfig := GrootFSConfig{
-		StorePath: fmt.Sprintf("/mnt/garden-storage/unprivileged-%d-%d", GinkgoParallelProcess(), storeTimestamp),
+		StorePath: fmt.Sprintf("/mnt/garden-storage/unprivileged-%d-%d", options.ParallelProcess, storeTimestamp),
 		DraxBin:   "/usr/local/bin/drax",
 		LogLevel:  "debug",
 	}
@@ -288,7 +400,7 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 	unprivilegedGrootfsConfig.Create.SkipLayerValidation = true
 
 	privilegedGrootfsConfig := GrootFSConfig{
-		StorePath: fmt.Sprintf("/mnt/garden-storage/privileged-%d-%d", GinkgoParallelProcess(), storeTimestamp),
+		StorePath: fmt.Sprintf("/mnt/garden-storage/privileged-%d-%d", options.ParallelProcess, storeTimestamp),
 		DraxBin:   "/usr/local/bin/drax",
 		LogLevel:  "debug",
 	}
@@ -312,12 +424,27 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 	}
 
 	guid, err := uuid.NewV4()
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
+
+	volmanConfigDir, err := NewTempDirWithParent(tmpDir, guid.String())
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
 
-	volmanConfigDir := TempDirWithParent(tmpDir, guid.String())
+	locketClient, err := newLocketClient(locket.ClientLocketConfig{
+		LocketAddress:        worldAddresses.Locket,
+		LocketCACertFile:     locketSSLConfig.CACert,
+		LocketClientCertFile: locketSSLConfig.ClientCert,
+		LocketClientKeyFile:  locketSSLConfig.ClientKey,
+	})
+	if err != nil {
+		return commonComponentFactory{}, err
+	}
 
 	dbDriverName, dbBaseConnectionString := DBInfo()
-	return commonComponentMaker{
+	return commonComponentFactory{
 		artifacts: builtArtifacts,
 		addresses: worldAddresses,
 
@@ -330,6 +457,7 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 		repSSL:                 repSSLConfig,
 		auctioneerSSL:          auctioneerSSLConfig,
 		routingAPISSL:          routingApiSSLConfig,
+		certificates:           certificates,
 		sqlCACertFile:          sqlCACert,
 		volmanDriverConfigDir:  volmanConfigDir,
 		dbDriverName:           dbDriverName,
@@ -338,57 +466,81 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 		portAllocator: allocator,
 
 		startCheckTimeout: startCheckTimeout,
+		readiness:         readiness,
+		fakeGarden:        options.FakeGarden,
+		healthChecks:      newHealthChecks(),
+		bbsInstances:      newBBSInstances(),
+		configs:           newComponentConfigs(),
+		runners:           newComponentRunners(),
+		locketClient:      locketClient,
 
-		tmpDir: tmpDir,
-	}
-}
-
-func MakeV0ComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority) ComponentMaker {
-	return v0ComponentMaker{commonComponentMaker: makeCommonComponentMaker(builtArtifacts, worldAddresses, allocator, certAuthority)}
-}
+		parallelProcess: options.ParallelProcess,
+		output:          options.Output,
 
-func MakeComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority) ComponentMaker {
-	return v1ComponentMaker{commonComponentMaker: makeCommonComponentMaker(builtArtifacts, worldAddresses, allocator, certAuthority)}
+		tmpDir: tmpDir,
+	}, nil
 }
 
-type ComponentMaker interface {
+// ComponentFactory builds runners and clients for the Diego components and
+// reports failures as errors, so that it can be used outside of ginkgo.
+// ComponentMaker wraps it for use in specs.
+type ComponentFactory interface {
 	VolmanDriverConfigDir() string
 	SSHConfig() SSHKeys
 	Artifacts() BuiltArtifacts
 	PortAllocator() portauthority.PortAllocator
 	Addresses() ComponentAddresses
-	Auctioneer(modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) *ginkgomon.Runner
-	BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) *ginkgomon.Runner
-	BBSClient() bbs.InternalClient
-	RepClientFactory() rep.ClientFactory
-	BBSServiceClient(logger lager.Logger) serviceclient.ServiceClient
+	ParallelProcess() int
+	Configs(since time.Time) []ComponentConfig
+	ClearConfigs()
+	Runners(since time.Time) []ComponentRunner
+	ClearRunners()
+	Auctioneer(modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) (*ginkgomon.Runner, error)
+	AuctioneerN(n int, modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) (*ginkgomon.Runner, error)
+	AuctioneerLockHolder(logger lager.Logger) (int, error)
+	BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) (*ginkgomon.Runner, error)
+	BBSN(n int, modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) (*ginkgomon.Runner, error)
+	BBSInstance(n int) (BBSInstance, error)
+	BBSLockHolder(logger lager.Logger) (int, error)
+	BBSClient() (bbs.InternalClient, error)
+	RepClientFactory() (rep.ClientFactory, error)
+	BBSServiceClient(logger lager.Logger) (serviceclient.ServiceClient, error)
 	BBSURL() string
 	BBSSSLConfig() SSLConfig
 	DefaultStack() string
-	FileServer() (ifrit.Runner, string)
-	Garden(fs ...func(*runner.GdnRunnerConfig)) *runner.GardenRunner
+	FakeGarden() *fakegarden.Garden
+	FaultProxy(target string) (*faultproxy.Proxy, error)
+	FileServer() (ifrit.Runner, string, error)
+	Garden(fs ...func(*runner.GdnRunnerConfig)) (*runner.GardenRunner, error)
 	GardenClient() garden.Client
-	GardenWithoutDefaultStack() ifrit.Runner
-	GrootFSDeleteStore()
-	GrootFSInitStore()
-	Locket(modifyConfigFuncs ...func(*locketconfig.LocketConfig)) ifrit.Runner
-	NATS(argv ...string) ifrit.Runner
-	Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner
-	RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner
+	GardenWithoutDefaultStack() (ifrit.Runner, error)
+	GrootFSDeleteStore() error
+	GrootFSInitStore() error
+	Locket(modifyConfigFuncs ...func(*locketconfig.LocketConfig)) (ifrit.Runner, error)
+	LockOwner(logger lager.Logger, key string) (string, error)
+	NATS(argv ...string) (ifrit.Runner, error)
+	Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) (*ginkgomon.Runner, error)
+	RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) (*ginkgomon.Runner, error)
 	RepSSLConfig() SSLConfig
-	RouteEmitter(fs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner
-	RouteEmitterN(n int, fs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner
-	Router() *ginkgomon.Runner
-	RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) *routingapi.RoutingAPIRunner
+	ReissueCertificates() error
+	RestoreCertificates() error
+	RetireOldCAs() error
+	RotateCA(commonName string) error
+	RouteEmitter(fs ...func(config *routeemitterconfig.RouteEmitterConfig)) (*ginkgomon.Runner, error)
+	RouteEmitterN(n int, fs ...func(config *routeemitterconfig.RouteEmitterConfig)) (*ginkgomon.Runner, error)
+	Router() (*ginkgomon.Runner, error)
+	RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) (*routingapi.RoutingAPIRunner, error)
 	SQL(argv ...string) ifrit.Runner
-	SSHProxy(modifyConfigFuncs ...func(*sshproxyconfig.SSHProxyConfig)) ifrit.Runner
-	Setup()
-	Teardown()
-	VolmanClient(logger lager.Logger) (volman.Manager, ifrit.Runner)
-	VolmanDriver(logger lager.Logger) (ifrit.Runner, dockerdriver.Driver)
+	SSHProxy(modifyConfigFuncs ...func(*sshproxyconfig.SSHProxyConfig)) (ifrit.Runner, error)
+	Setup() error
+	Teardown() error
+	VolmanClient(logger lager.Logger) (volman.Manager, ifrit.Runner, error)
+	VolmanDriver(logger lager.Logger) (ifrit.Runner, dockerdriver.Driver, error)
+	WithReadinessCheck(runner ifrit.Runner) ifrit.Runner
+	WithReadiness(mode ReadinessMode) ComponentFactory
 }
 
-type commonComponentMaker struct {
+type commonComponentFactory struct {
 	artifacts              BuiltArtifacts
 	addresses              ComponentAddresses
 	rootFSes               repconfig.RootFSes
@@ -399,135 +551,219 @@ type commonComponentMaker struct {
 	repSSL                 SSLConfig
 	auctioneerSSL          SSLConfig
 	routingAPISSL          SSLConfig
+	certificates           *certificates
 	sqlCACertFile          string
 	volmanDriverConfigDir  string
 	dbDriverName           string
 	dbBaseConnectionString string
 	portAllocator          portauthority.PortAllocator
 	startCheckTimeout      time.Duration
+	readiness              ReadinessMode
+	fakeGarden             bool
+	healthChecks           *healthChecks
+	bbsInstances           *bbsInstances
+	configs                *componentConfigs
+	locketClient           *locketClient
+	runners                *componentRunners
+	parallelProcess        int
+	output                 io.Writer
 	tmpDir                 string
 }
 
-func (maker commonComponentMaker) VolmanDriverConfigDir() string {
+func (maker commonComponentFactory) VolmanDriverConfigDir() string {
 	return maker.volmanDriverConfigDir
 }
 
-func (maker commonComponentMaker) SSHConfig() SSHKeys {
+func (maker commonComponentFactory) SSHConfig() SSHKeys {
 	return maker.sshConfig
 }
 
-func (maker commonComponentMaker) PortAllocator() portauthority.PortAllocator {
+func (maker commonComponentFactory) PortAllocator() portauthority.PortAllocator {
 	return maker.portAllocator
 }
 
-func (maker commonComponentMaker) Artifacts() BuiltArtifacts {
+func (maker commonComponentFactory) Artifacts() BuiltArtifacts {
 	return maker.artifacts
 }
 
-func (maker commonComponentMaker) Addresses() ComponentAddresses {
+func (maker commonComponentFactory) Addresses() ComponentAddresses {
 	return maker.addresses
 }
 
-func (maker commonComponentMaker) BBSSSLConfig() SSLConfig {
+func (maker commonComponentFactory) ParallelProcess() int {
+	return maker.parallelProcess
+}
+
+func (maker commonComponentFactory) BBSSSLConfig() SSLConfig {
 	return maker.bbsSSL
 }
 
-func (maker commonComponentMaker) RepSSLConfig() SSLConfig {
+func (maker commonComponentFactory) RepSSLConfig() SSLConfig {
 	return maker.repSSL
 }
 
-func (maker commonComponentMaker) Setup() {
-	if runtime.GOOS != "windows" {
-		maker.GrootFSInitStore()
+func (maker commonComponentFactory) Setup() error {
+	if runtime.GOOS != "windows" && !maker.fakeGarden {
+		return maker.GrootFSInitStore()
 	}
+	return nil
 }
 
-func (maker commonComponentMaker) Teardown() {
+func (maker commonComponentFactory) Teardown() error {
+	err := maker.locketClient.close()
+	if err != nil {
+		return err
+	}
+
 	deleteTmpDir := func() error { return os.RemoveAll(maker.tmpDir) }
 	if runtime.GOOS != "windows" {
-		maker.GrootFSDeleteStore()
-		Eventually(deleteTmpDir, time.Minute).Should(Succeed())
-	} else {
-		// #nosec G104 - auctioneer is not getting stopped on windows. Catching error will cause the test to fail.
-		deleteTmpDir()
+		if !maker.fakeGarden {
+			err = maker.GrootFSDeleteStore()
+			if err != nil {
+				return err
+			}
+		}
+		return retryFor(time.Minute, deleteTmpDir)
 	}
+
+	// #nosec G104 - auctioneer is not getting stopped on windows. Catching error will cause the test to fail.
+	deleteTmpDir()
+	return nil
 }
 
-func (maker commonComponentMaker) NATS(argv ...string) ifrit.Runner {
+// NATS runs the nats-server binary at $NATS_SERVER_BINARY, or an embedded
+// nats-server in this process if that is unset. argv holds extra nats-server
+// command line flags in either case.
+func (maker commonComponentFactory) NATS(argv ...string) (ifrit.Runner, error) {
 	host, port, err := net.SplitHostPort(maker.addresses.NATS)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{
+		"--addr", host,
+		"--port", port,
+	}, argv...)
 
 	natsServerPath, exists := os.LookupEnv("NATS_SERVER_BINARY")
 	if !exists {
-		fmt.Println("You need nats-server install set NATS_SERVER_BINARY env variable")
-		os.Exit(1)
+		return maker.embeddedNATS(args)
 	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.newRunner(ginkgomon.Config{
 		Name:              "nats-server",
 		AnsiColorCode:     "30m",
 		StartCheck:        "Server is ready",
 		StartCheckTimeout: maker.startCheckTimeout,
-		Command: exec.Command(
-			natsServerPath,
-			append([]string{
-				"--addr", host,
-				"--port", port,
-			}, argv...)...,
-		),
-	})
+		Command:           exec.Command(natsServerPath, args...),
+	}), nil
 }
 
-func (maker commonComponentMaker) SQL(argv ...string) ifrit.Runner {
+func (maker commonComponentFactory) embeddedNATS(args []string) (ifrit.Runner, error) {
+	flags := flag.NewFlagSet("nats-server", flag.ContinueOnError)
+	flags.SetOutput(maker.output)
+
+	// the version and help flags would otherwise exit the process
+	noop := func() {}
+	opts, err := natsserver.ConfigureOptions(flags, args, noop, noop, noop)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nats-server options %v: %w", args, err)
+	}
+	opts.NoSigs = true
+
 	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
-		defer GinkgoRecover()
+		natsServer, err := natsserver.NewServer(opts)
+		if err != nil {
+			return err
+		}
+		natsServer.ConfigureLogger()
+
+		go natsServer.Start()
+
+		if !natsServer.ReadyForConnections(maker.startCheckTimeout) {
+			natsServer.Shutdown()
+			return fmt.Errorf("embedded nats-server was not ready for connections within %s", maker.startCheckTimeout)
+		}
+
+		close(ready)
+
+		<-signals
+		natsServer.Shutdown()
+		natsServer.WaitForShutdown()
+		return nil
+	}), nil
+}
 
-		logger := lagertest.NewTestLogger("component-maker")
+func (maker commonComponentFactory) SQL(argv ...string) ifrit.Runner {
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		logger := lager.NewLogger("component-maker")
+		logger.RegisterSink(lager.NewWriterSink(maker.output, lager.DEBUG))
 
 		db, err := helpers.Connect(logger, maker.dbDriverName, maker.dbBaseConnectionString, "", false)
-		Expect(err).NotTo(HaveOccurred())
+		if err != nil {
+			return err
+		}
 		defer db.Close()
 
-		Eventually(db.Ping).Should(Succeed())
+		err = retryFor(pingTimeout, db.Ping)
+		if err != nil {
+			return err
+		}
 
-		sqlDBName := fmt.Sprintf("diego_%d", GinkgoParallelProcess())
+		sqlDBName := fmt.Sprintf("diego_%d", maker.parallelProcess)
 		// #nosec G104 - ignore errors dropping databases that don't exist, we just want a clean slate
 		db.Exec(fmt.Sprintf("DROP DATABASE %s", sqlDBName))
 		_, err = db.Exec(fmt.Sprintf("CREATE DATABASE %s", sqlDBName))
-		Expect(err).NotTo(HaveOccurred())
+		if err != nil {
+			return err
+		}
 
 		dbWithDatabaseNameConnectionString := fmt.Sprintf("%s%s", maker.dbBaseConnectionString, sqlDBName)
-		db, err = helpers.Connect(logger, maker.dbDriverName, dbWithDatabaseNameConnectionString, "", false)
-		Expect(err).NotTo(HaveOccurred())
-		Eventually(db.Ping).Should(Succeed())
+		namedDB, err := helpers.Connect(logger, maker.dbDriverName, dbWithDatabaseNameConnectionString, "", false)
+		if err != nil {
+			return err
+		}
+		err = retryFor(pingTimeout, namedDB.Ping)
+		if err != nil {
+			namedDB.Close()
+			return err
+		}
 
-		Expect(db.Close()).To(Succeed())
+		err = namedDB.Close()
+		if err != nil {
+			return err
+		}
 
 		close(ready)
 
 		<-signals
-		db, err = helpers.Connect(logger, maker.dbDriverName, maker.dbBaseConnectionString, "", false)
-		Expect(err).NotTo(HaveOccurred())
-		Eventually(db.Ping).ShouldNot(HaveOccurred())
+		err = retryFor(pingTimeout, db.Ping)
+		if err != nil {
+			return err
+		}
 
 		_, err = db.Exec(fmt.Sprintf("DROP DATABASE %s", sqlDBName))
-		Expect(err).NotTo(HaveOccurred())
-
-		return nil
+		return err
 	})
 }
 
-func (maker commonComponentMaker) GrootFSInitStore() {
+func (maker commonComponentFactory) GrootFSInitStore() error {
 	err := maker.grootfsInitStore(maker.gardenConfig.UnprivilegedGrootfsConfig)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return err
+	}
 
-	err = maker.grootfsInitStore(maker.gardenConfig.PrivilegedGrootfsConfig)
-	Expect(err).NotTo(HaveOccurred())
+	return maker.grootfsInitStore(maker.gardenConfig.PrivilegedGrootfsConfig)
 }
 
-func (maker commonComponentMaker) grootfsInitStore(grootfsConfig GrootFSConfig) error {
+func (maker commonComponentFactory) grootfsInitStore(grootfsConfig GrootFSConfig) error {
+	configPath, err := maker.grootfsConfigPath(grootfsConfig)
+	if err != nil {
+		return err
+	}
+
 	grootfsArgs := []string{}
-	grootfsArgs = append(grootfsArgs, "--config", maker.grootfsConfigPath(grootfsConfig))
+	grootfsArgs = append(grootfsArgs, "--config", configPath)
 	grootfsArgs = append(grootfsArgs, "init-store")
 	for _, mapping := range grootfsConfig.Create.UidMappings {
 		grootfsArgs = append(grootfsArgs, "--uid-mapping", mapping)
@@ -539,61 +775,90 @@ func (maker commonComponentMaker) grootfsInitStore(grootfsConfig GrootFSConfig)
 	return maker.grootfsRunner(grootfsArgs)
 }
 
-func (maker commonComponentMaker) GrootFSDeleteStore() {
+func (maker commonComponentFactory) GrootFSDeleteStore() error {
 	err := maker.grootfsDeleteStore(maker.gardenConfig.UnprivilegedGrootfsConfig)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return err
+	}
 
-	err = maker.grootfsDeleteStore(maker.gardenConfig.PrivilegedGrootfsConfig)
-	Expect(err).NotTo(HaveOccurred())
+	return maker.grootfsDeleteStore(maker.gardenConfig.PrivilegedGrootfsConfig)
 }
 
-func (maker commonComponentMaker) grootfsDeleteStore(grootfsConfig GrootFSConfig) error {
+func (maker commonComponentFactory) grootfsDeleteStore(grootfsConfig GrootFSConfig) error {
+	configPath, err := maker.grootfsConfigPath(grootfsConfig)
+	if err != nil {
+		return err
+	}
+
 	grootfsArgs := []string{}
-	grootfsArgs = append(grootfsArgs, "--config", maker.grootfsConfigPath(grootfsConfig))
+	grootfsArgs = append(grootfsArgs, "--config", configPath)
 	grootfsArgs = append(grootfsArgs, "delete-store")
 	return maker.grootfsRunner(grootfsArgs)
 }
 
-func (maker commonComponentMaker) grootfsRunner(args []string) error {
+func (maker commonComponentFactory) grootfsRunner(args []string) error {
 	cmd := exec.Command(filepath.Join(maker.gardenConfig.GardenBinPath, "grootfs"), args...)
-	cmd.Stderr = GinkgoWriter
-	cmd.Stdout = GinkgoWriter
+	cmd.Stderr = maker.output
+	cmd.Stdout = maker.output
 	return cmd.Run()
 }
 
-func (maker commonComponentMaker) grootfsConfigPath(grootfsConfig GrootFSConfig) string {
+func (maker commonComponentFactory) grootfsConfigPath(grootfsConfig GrootFSConfig) (string, error) {
 	configFile, err := os.CreateTemp("", "grootfs-config")
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return "", err
+	}
 	defer configFile.Close()
 	data, err := yaml.Marshal(&grootfsConfig)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return "", err
+	}
 	_, err = configFile.Write(data)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return "", err
+	}
 
-	return configFile.Name()
+	return configFile.Name(), nil
 }
 
-func (maker commonComponentMaker) networkPluginConfigPath(networkPluginConfig NetworkPluginConfig) string {
-	configFile, err := os.CreateTemp(TempDirWithParent(maker.tmpDir, "network-plugin"), "network-plugin-config")
-	Expect(err).NotTo(HaveOccurred())
+func (maker commonComponentFactory) networkPluginConfigPath(networkPluginConfig NetworkPluginConfig) (string, error) {
+	configFile, err := maker.createConfigFile("network-plugin", "network-plugin-config")
+	if err != nil {
+		return "", err
+	}
 	defer configFile.Close()
 	data, err := json.Marshal(&networkPluginConfig)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return "", err
+	}
 	_, err = configFile.Write(data)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return "", err
+	}
+
+	return configFile.Name(), nil
+}
+
+// createConfigFile creates an empty file named after pattern in a new
+// directory named after dirPrefix inside the factory's temporary directory.
+func (maker commonComponentFactory) createConfigFile(dirPrefix, pattern string) (*os.File, error) {
+	configDir, err := NewTempDirWithParent(maker.tmpDir, dirPrefix)
+	if err != nil {
+		return nil, err
+	}
 
-	return configFile.Name()
+	return os.CreateTemp(configDir, pattern)
 }
 
-func (maker commonComponentMaker) GardenWithoutDefaultStack() ifrit.Runner {
+func (maker commonComponentFactory) GardenWithoutDefaultStack() (ifrit.Runner, error) {
 	return maker.garden(false)
 }
 
-func (maker commonComponentMaker) Garden(fs ...func(*runner.GdnRunnerConfig)) *runner.GardenRunner {
+func (maker commonComponentFactory) Garden(fs ...func(*runner.GdnRunnerConfig)) (*runner.GardenRunner, error) {
 	return maker.garden(true, fs...)
 }
 
-func (maker commonComponentMaker) garden(includeDefaultStack bool, fs ...func(*runner.GdnRunnerConfig)) *runner.GardenRunner {
+func (maker commonComponentFactory) garden(includeDefaultStack bool, fs ...func(*runner.GdnRunnerConfig)) (*runner.GardenRunner, error) {
 	defaultRootFS := ""
 	if includeDefaultStack {
 		defaultRootFS = maker.rootFSes.StackPathMap()[maker.DefaultStack()]
@@ -616,9 +881,13 @@ func (maker commonComponentMaker) garden(includeDefaultStack bool, fs ...func(*r
 			maker.gardenConfig.GrootFSStorePath,
 		}
 		config.NetworkPluginBin = filepath.Join(maker.gardenConfig.GardenBinPath, "winc-network.exe")
+		networkPluginConfigPath, err := maker.networkPluginConfigPath(maker.gardenConfig.NetworkPluginConfig)
+		if err != nil {
+			return nil, err
+		}
 		config.NetworkPluginExtraArgs = []string{
 			"\"--configFile\"",
-			maker.networkPluginConfigPath(maker.gardenConfig.NetworkPluginConfig),
+			networkPluginConfigPath,
 		}
 
 		maxContainers := uint64(20)
@@ -632,18 +901,28 @@ func (maker commonComponentMaker) garden(includeDefaultStack bool, fs ...func(*r
 		config.ImagePluginBin = filepath.Join(maker.gardenConfig.GardenBinPath, "grootfs")
 		config.PrivilegedImagePluginBin = filepath.Join(maker.gardenConfig.GardenBinPath, "grootfs")
 
+		unprivilegedConfigPath, err := maker.grootfsConfigPath(maker.gardenConfig.UnprivilegedGrootfsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		privilegedConfigPath, err := maker.grootfsConfigPath(maker.gardenConfig.PrivilegedGrootfsConfig)
+		if err != nil {
+			return nil, err
+		}
+
 		// TODO: this is overriding the guardian runner args, which is fine since we
 		// don't use tardis (tardis is only required for overlay+xfs)
 		config.ImagePluginExtraArgs = []string{
 			"\"--config\"",
-			maker.grootfsConfigPath(maker.gardenConfig.UnprivilegedGrootfsConfig),
+			unprivilegedConfigPath,
 		}
 
 		// TODO: this is overriding the guardian runner args, which is fine since we
 		// don't use tardis (tardis is only required for overlay+xfs)
 		config.PrivilegedImagePluginExtraArgs = []string{
 			"\"--config\"",
-			maker.grootfsConfigPath(maker.gardenConfig.PrivilegedGrootfsConfig),
+			privilegedConfigPath,
 		}
 
 		config.DenyNetworks = []string{"0.0.0.0/0"}
@@ -652,44 +931,54 @@ func (maker commonComponentMaker) garden(includeDefaultStack bool, fs ...func(*r
 		poolSize := 10
 		config.PortPoolSize = &poolSize
 		ports, err := maker.portAllocator.ClaimPorts(*config.PortPoolSize)
+		if err != nil {
+			return nil, err
+		}
 		startPort := int(ports)
-		Expect(err).NotTo(HaveOccurred())
 		config.PortPoolStart = &startPort
 	}
 
 	config.DefaultRootFS = defaultRootFS
 
 	host, port, err := net.SplitHostPort(maker.addresses.Garden)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
 	config.BindSocket = ""
 	config.BindIP = host
 
 	intPort, err := strconv.Atoi(port)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 	config.BindPort = intPtr(intPort)
 
 	for _, f := range fs {
 		f(&config)
 	}
+	maker.recordConfig("garden", config)
 
 	gardenRunner := runner.NewGardenRunner(config)
 	gardenRunner.Runner.StartCheck = "guardian.started"
 	gardenRunner.Runner.StartCheckTimeout = maker.startCheckTimeout
+	maker.recordRunner(gardenRunner.Runner)
 
-	return gardenRunner
+	return gardenRunner, nil
 }
 
-func (maker commonComponentMaker) RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) *routingapi.RoutingAPIRunner {
+func (maker commonComponentFactory) RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) (*routingapi.RoutingAPIRunner, error) {
 	binPath := maker.artifacts.Executables["routing-api"]
 
 	sqlConfig := routingapi.SQLConfig{
 		DriverName: maker.dbDriverName,
-		DBName:     fmt.Sprintf("routingapi_%d", GinkgoParallelProcess()),
+		DBName:     fmt.Sprintf("routingapi_%d", maker.parallelProcess),
 	}
 
 	port, err := maker.portAllocator.ClaimPorts(2)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
 	user, ok := os.LookupEnv("DB_USER")
 	if !ok {
@@ -730,36 +1019,68 @@ func (maker commonComponentMaker) RoutingAPI(modifyConfigFuncs ...func(*routinga
 		}
 	})
 
-	runner, err := routingapi.NewRoutingAPIRunner(binPath, int(port+1), sqlConfig, modifyConfigFuncs...)
-	Expect(err).NotTo(HaveOccurred())
-	return runner
-}
-
-func (maker commonComponentMaker) Locket(modifyConfigFuncs ...func(*locketconfig.LocketConfig)) ifrit.Runner {
-	return locketrunner.NewLocketRunner(maker.artifacts.Executables["locket"], func(cfg *locketconfig.LocketConfig) {
-		cfg.CertFile = maker.locketSSL.ServerCert
-		cfg.KeyFile = maker.locketSSL.ServerKey
-		cfg.CaFile = maker.locketSSL.CACert
-		cfg.DatabaseConnectionString = maker.addresses.SQL
-		cfg.DatabaseDriver = maker.dbDriverName
-		cfg.ListenAddress = maker.addresses.Locket
-		cfg.SQLCACertFile = maker.sqlCACertFile
-		cfg.LagerConfig = lagerflags.LagerConfig{
+	return routingapi.NewRoutingAPIRunner(binPath, int(port+1), sqlConfig, modifyConfigFuncs...)
+}
+
+func (maker commonComponentFactory) Locket(modifyConfigFuncs ...func(*locketconfig.LocketConfig)) (ifrit.Runner, error) {
+	configFile, err := maker.createConfigFile("locket", "locket-config")
+	if err != nil {
+		return nil, err
+	}
+	defer configFile.Close()
+
+	cfg := locketconfig.LocketConfig{
+		CertFile:                 maker.locketSSL.ServerCert,
+		KeyFile:                  maker.locketSSL.ServerKey,
+		CaFile:                   maker.locketSSL.CACert,
+		DatabaseConnectionString: maker.addresses.SQL,
+		DatabaseDriver:           maker.dbDriverName,
+		ListenAddress:            maker.addresses.Locket,
+		SQLCACertFile:            maker.sqlCACertFile,
+		ReportInterval:           durationjson.Duration(time.Minute),
+		LagerConfig: lagerflags.LagerConfig{
 			LogLevel:   "debug",
 			TimeFormat: lagerflags.FormatRFC3339,
-		}
+		},
+	}
 
-		for _, modifyConfig := range modifyConfigFuncs {
-			modifyConfig(cfg)
-		}
+	for _, modifyConfig := range modifyConfigFuncs {
+		modifyConfig(&cfg)
+	}
+	maker.recordConfig("locket", cfg)
+
+	encoder := json.NewEncoder(configFile)
+	err = encoder.Encode(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	locketRunner := maker.newRunner(ginkgomon.Config{
+		Name:              "locket",
+		AnsiColorCode:     "33m",
+		StartCheck:        "locket.started",
+		StartCheckTimeout: maker.startCheckTimeout,
+		Command: exec.Command(
+			maker.artifacts.Executables["locket"],
+			"-config="+configFile.Name(),
+		),
+		Cleanup: func() {
+			// #nosec G104 - the factory's temporary directory is removed on teardown anyway
+			os.RemoveAll(configFile.Name())
+		},
 	})
+
+	maker.registerHealthCheck(locketRunner, grpcHealthCheck(cfg.ListenAddress, maker.locketSSL))
+	return locketRunner, nil
 }
 
-func (maker commonComponentMaker) RouteEmitterN(n int, fs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner {
+func (maker commonComponentFactory) RouteEmitterN(n int, fs ...func(config *routeemitterconfig.RouteEmitterConfig)) (*ginkgomon.Runner, error) {
 	name := "route-emitter-" + strconv.Itoa(n)
 
-	configFile, err := os.CreateTemp(TempDirWithParent(maker.tmpDir, "route-emitter"), "route-emitter-config")
-	Expect(err).NotTo(HaveOccurred())
+	configFile, err := maker.createConfigFile("route-emitter", "route-emitter-config")
+	if err != nil {
+		return nil, err
+	}
 	defer configFile.Close()
 
 	cfg := routeemitterconfig.RouteEmitterConfig{
@@ -793,12 +1114,15 @@ func (maker commonComponentMaker) RouteEmitterN(n int, fs ...func(config *routee
 	for _, f := range fs {
 		f(&cfg)
 	}
+	maker.recordConfig(name, cfg)
 
 	encoder := json.NewEncoder(configFile)
 	err = encoder.Encode(&cfg)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.newRunner(ginkgomon.Config{
 		Name:              name,
 		AnsiColorCode:     "36m",
 		StartCheck:        `"` + name + `.watcher.sync.complete"`,
@@ -808,17 +1132,22 @@ func (maker commonComponentMaker) RouteEmitterN(n int, fs ...func(config *routee
 			"-config", configFile.Name(),
 		),
 		Cleanup: func() {
-			err := os.RemoveAll(configFile.Name())
-			Expect(err).ToNot(HaveOccurred())
+			// #nosec G104 - the factory's temporary directory is removed on teardown anyway
+			os.RemoveAll(configFile.Name())
 		},
-	})
+	}), nil
 }
 
-func (maker commonComponentMaker) FileServer() (ifrit.Runner, string) {
-	servedFilesDir := TempDirWithParent(maker.tmpDir, "file-server-files")
+func (maker commonComponentFactory) FileServer() (ifrit.Runner, string, error) {
+	servedFilesDir, err := NewTempDirWithParent(maker.tmpDir, "file-server-files")
+	if err != nil {
+		return nil, "", err
+	}
 
-	configFile, err := os.CreateTemp(TempDirWithParent(maker.tmpDir, "file-server"), "file-server-config")
-	Expect(err).NotTo(HaveOccurred())
+	configFile, err := maker.createConfigFile("file-server", "file-server-config")
+	if err != nil {
+		return nil, "", err
+	}
 	defer configFile.Close()
 
 	cfg := fileserverconfig.FileServerConfig{
@@ -832,7 +1161,9 @@ func (maker commonComponentMaker) FileServer() (ifrit.Runner, string) {
 
 	buildpackAppLifeCycleDir := filepath.Join(servedFilesDir, "buildpack_app_lifecycle")
 	err = os.Mkdir(buildpackAppLifeCycleDir, 0755)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, "", err
+	}
 	file := maker.artifacts.Lifecycles["buildpackapplifecycle"]
 	if _, err := os.Stat(file); !os.IsNotExist(err) {
 		var cmd *exec.Cmd
@@ -842,12 +1173,16 @@ func (maker commonComponentMaker) FileServer() (ifrit.Runner, string) {
 			cmd = exec.Command("cp", file, filepath.Join(buildpackAppLifeCycleDir, "buildpack_app_lifecycle.tgz"))
 		}
 		err = cmd.Run()
-		Expect(err).NotTo(HaveOccurred())
+		if err != nil {
+			return nil, "", err
+		}
 	}
 
 	dockerAppLifeCycleDir := filepath.Join(servedFilesDir, "docker_app_lifecycle")
 	err = os.Mkdir(dockerAppLifeCycleDir, 0755)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, "", err
+	}
 	file = maker.artifacts.Lifecycles["dockerapplifecycle"]
 	if _, err := os.Stat(file); !os.IsNotExist(err) {
 		var cmd *exec.Cmd
@@ -857,14 +1192,20 @@ func (maker commonComponentMaker) FileServer() (ifrit.Runner, string) {
 			cmd = exec.Command("cp", file, filepath.Join(dockerAppLifeCycleDir, "docker_app_lifecycle.tgz"))
 		}
 		err = cmd.Run()
-		Expect(err).NotTo(HaveOccurred())
+		if err != nil {
+			return nil, "", err
+		}
 	}
 
+	maker.recordConfig("file-server", cfg)
+
 	encoder := json.NewEncoder(configFile)
 	err = encoder.Encode(&cfg)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, "", err
+	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.newRunner(ginkgomon.Config{
 		Name:              "file-server",
 		AnsiColorCode:     "92m",
 		StartCheck:        `"file-server.ready"`,
@@ -874,43 +1215,44 @@ func (maker commonComponentMaker) FileServer() (ifrit.Runner, string) {
 			"-config", configFile.Name(),
 		),
 		Cleanup: func() {
-			err := os.RemoveAll(servedFilesDir)
-			Expect(err).ToNot(HaveOccurred())
-			err = os.RemoveAll(configFile.Name())
-			Expect(err).ToNot(HaveOccurred())
+			// #nosec G104 - the factory's temporary directory is removed on teardown anyway
+			os.RemoveAll(servedFilesDir)
+			// #nosec G104 - the factory's temporary directory is removed on teardown anyway
+			os.RemoveAll(configFile.Name())
 		},
-	}), servedFilesDir
+	}), servedFilesDir, nil
 }
 
-func (maker commonComponentMaker) Router() *ginkgomon.Runner {
-	_, routerPort, err := net.SplitHostPort(maker.addresses.Router)
-	Expect(err).NotTo(HaveOccurred())
-
-	routerPortInt, err := strconv.Atoi(routerPort)
-	Expect(err).NotTo(HaveOccurred())
-
-	_, routerStatusPort, err := net.SplitHostPort(maker.addresses.RouterStatus)
-	Expect(err).NotTo(HaveOccurred())
-
-	routerStatusPortInt, err := strconv.Atoi(routerStatusPort)
-	Expect(err).NotTo(HaveOccurred())
+func (maker commonComponentFactory) Router() (*ginkgomon.Runner, error) {
+	routerPortInt, err := addressPort(maker.addresses.Router)
+	if err != nil {
+		return nil, err
+	}
 
-	_, routerRoutesPort, err := net.SplitHostPort(maker.addresses.RouterRoutes)
-	Expect(err).NotTo(HaveOccurred())
+	routerStatusPortInt, err := addressPort(maker.addresses.RouterStatus)
+	if err != nil {
+		return nil, err
+	}
 
-	routerRoutesPortInt, err := strconv.Atoi(routerRoutesPort)
-	Expect(err).NotTo(HaveOccurred())
+	routerRoutesPortInt, err := addressPort(maker.addresses.RouterRoutes)
+	if err != nil {
+		return nil, err
+	}
 
-	_, routerRouteServicesPort, err := net.SplitHostPort(maker.addresses.RouterRouteServices)
-	Expect(err).NotTo(HaveOccurred())
+	routerRouteServicesPortInt, err := addressPort(maker.addresses.RouterRouteServices)
+	if err != nil {
+		return nil, err
+	}
 
-	routerRouteServicesPortInt, err := strconv.Atoi(routerRouteServicesPort)
-	Expect(err).NotTo(HaveOccurred())
 	natsHost, natsPort, err := net.SplitHostPort(maker.addresses.NATS)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
 	natsPortInt, err := strconv.Atoi(natsPort)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
 	routerConfig := `
 status:
@@ -989,13 +1331,24 @@ pid_file: ""
 `
 	routerConfig = fmt.Sprintf(routerConfig, uint16(routerStatusPortInt), uint16(routerRoutesPortInt), natsHost, uint16(natsPortInt), uint16(routerPortInt), uint16(routerRouteServicesPortInt))
 
-	configFile, err := os.CreateTemp(TempDirWithParent(maker.tmpDir, "router-config"), "router-config")
-	Expect(err).NotTo(HaveOccurred())
+	var recordedRouterConfig map[string]interface{}
+	err = yaml.Unmarshal([]byte(routerConfig), &recordedRouterConfig)
+	if err != nil {
+		return nil, err
+	}
+	maker.recordConfig("router", normalizeYAMLMap(recordedRouterConfig))
+
+	configFile, err := maker.createConfigFile("router-config", "router-config")
+	if err != nil {
+		return nil, err
+	}
 	defer configFile.Close()
 	_, err = configFile.Write([]byte(routerConfig))
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	routerRunner := maker.newRunner(ginkgomon.Config{
 		Name:              "router",
 		AnsiColorCode:     "93m",
 		StartCheck:        "router.started",
@@ -1005,13 +1358,16 @@ pid_file: ""
 			"-c", configFile.Name(),
 		),
 		Cleanup: func() {
-			err := os.Remove(configFile.Name())
-			Expect(err).NotTo(HaveOccurred())
+			// #nosec G104 - the factory's temporary directory is removed on teardown anyway
+			os.Remove(configFile.Name())
 		},
 	})
+
+	maker.registerHealthCheck(routerRunner, httpHealthCheck(fmt.Sprintf("http://127.0.0.1:%d/health", routerStatusPortInt)))
+	return routerRunner, nil
 }
 
-func (maker commonComponentMaker) SSHProxy(modifyConfigFuncs ...func(*sshproxyconfig.SSHProxyConfig)) ifrit.Runner {
+func (maker commonComponentFactory) SSHProxy(modifyConfigFuncs ...func(*sshproxyconfig.SSHProxyConfig)) (ifrit.Runner, error) {
 	sshProxyConfig := sshproxyconfig.SSHProxyConfig{
 		Address:            maker.addresses.SSHProxy,
 		HealthCheckAddress: maker.addresses.SSHProxyHealthCheck,
@@ -1033,16 +1389,21 @@ func (maker commonComponentMaker) SSHProxy(modifyConfigFuncs ...func(*sshproxyco
 	for _, f := range modifyConfigFuncs {
 		f(&sshProxyConfig)
 	}
+	maker.recordConfig("ssh-proxy", sshProxyConfig)
 
 	configFile, err := os.CreateTemp("", "ssh-proxy-config")
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 	defer configFile.Close()
 
 	encoder := json.NewEncoder(configFile)
 	err = encoder.Encode(&sshProxyConfig)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	sshProxyRunner := maker.newRunner(ginkgomon.Config{
 		Name:              "ssh-proxy",
 		AnsiColorCode:     "96m",
 		StartCheck:        "ssh-proxy.started",
@@ -1054,31 +1415,39 @@ func (maker commonComponentMaker) SSHProxy(modifyConfigFuncs ...func(*sshproxyco
 			}...,
 		),
 	})
+
+	maker.registerHealthCheck(sshProxyRunner, httpHealthCheck("http://"+sshProxyConfig.HealthCheckAddress+"/"))
+	return sshProxyRunner, nil
 }
 
-func (maker commonComponentMaker) DefaultStack() string {
-	Expect(maker.rootFSes).NotTo(BeEmpty())
+// DefaultStack returns the first preloaded stack, or an empty string if there
+// are none.
+func (maker commonComponentFactory) DefaultStack() string {
+	if len(maker.rootFSes) == 0 {
+		return ""
+	}
 	return maker.rootFSes.Names()[0]
 }
 
-func (maker commonComponentMaker) GardenClient() garden.Client {
+func (maker commonComponentFactory) GardenClient() garden.Client {
 	return gardenclient.New(gardenconnection.New("tcp", maker.addresses.Garden))
 }
 
-func (maker commonComponentMaker) BBSClient() bbs.InternalClient {
-	client, err := bbs.NewClient(
+func (maker commonComponentFactory) BBSClient() (bbs.InternalClient, error) {
+	return bbs.NewClient(
 		maker.BBSURL(),
 		maker.bbsSSL.CACert,
 		maker.bbsSSL.ClientCert,
 		maker.bbsSSL.ClientKey,
 		0, 0,
 	)
-	Expect(err).NotTo(HaveOccurred())
-	return client
 }
 
-func (maker commonComponentMaker) RepClientFactory() rep.ClientFactory {
-	Expect(maker.repSSL.CACert).To(BeAnExistingFile())
+func (maker commonComponentFactory) RepClientFactory() (rep.ClientFactory, error) {
+	_, err := os.Stat(maker.repSSL.CACert)
+	if err != nil {
+		return nil, err
+	}
 
 	tlsConfig := rep.TLSConfig{
 		RequireTLS:      true,
@@ -1089,36 +1458,42 @@ func (maker commonComponentMaker) RepClientFactory() rep.ClientFactory {
 	}
 
 	client := cfhttp.NewClient(cfhttp.WithRequestTimeout(10 * time.Second))
-	factory, err := rep.NewClientFactory(client, client, &tlsConfig)
-	Expect(err).NotTo(HaveOccurred())
-	return factory
+	return rep.NewClientFactory(client, client, &tlsConfig)
 }
 
-func (maker commonComponentMaker) BBSServiceClient(logger lager.Logger) serviceclient.ServiceClient {
+func (maker commonComponentFactory) BBSServiceClient(logger lager.Logger) (serviceclient.ServiceClient, error) {
 	locketClient, err := locket.NewClient(logger, maker.locketClientConfig())
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
-	return serviceclient.NewServiceClient(locketClient)
+	return serviceclient.NewServiceClient(locketClient), nil
 }
 
-func (maker commonComponentMaker) BBSURL() string {
+func (maker commonComponentFactory) BBSURL() string {
 	return "https://" + maker.addresses.BBS
 }
 
-func (maker commonComponentMaker) VolmanClient(logger lager.Logger) (volman.Manager, ifrit.Runner) {
+func (maker commonComponentFactory) VolmanClient(logger lager.Logger) (volman.Manager, ifrit.Runner, error) {
 	driverConfig := volmanclient.NewDriverConfig()
-	driverConfig.DriverPaths = []string{path.Join(maker.volmanDriverConfigDir, fmt.Sprintf("node-%d", GinkgoParallelProcess()))}
+	driverConfig.DriverPaths = []string{maker.volmanDriverPath()}
 
 	metronClient, err := loggingclient.NewIngressClient(loggingclient.Config{})
-	Expect(err).NotTo(HaveOccurred())
-	return volmanclient.NewServer(logger, metronClient, driverConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manager, volmanRunner := volmanclient.NewServer(logger, metronClient, driverConfig)
+	return manager, volmanRunner, nil
 }
 
-func (maker commonComponentMaker) VolmanDriver(logger lager.Logger) (ifrit.Runner, dockerdriver.Driver) {
+func (maker commonComponentFactory) VolmanDriver(logger lager.Logger) (ifrit.Runner, dockerdriver.Driver, error) {
 	debugServerPort, err := maker.portAllocator.ClaimPorts(1)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, nil, err
+	}
 	debugServerAddress := fmt.Sprintf("0.0.0.0:%d", debugServerPort)
-	fakeDriverRunner := ginkgomon.New(ginkgomon.Config{
+	fakeDriverRunner := maker.newRunner(ginkgomon.Config{
 		Name: "local-driver",
 		Command: exec.Command(
 			maker.artifacts.Executables["local-driver"],
@@ -1126,7 +1501,7 @@ func (maker commonComponentMaker) VolmanDriver(logger lager.Logger) (ifrit.Runne
 			"-debugAddr", debugServerAddress,
 			"-mountDir", maker.volmanDriverConfigDir,
 			"-logLevel", "debug",
-			"-driversPath", path.Join(maker.volmanDriverConfigDir, fmt.Sprintf("node-%d", GinkgoParallelProcess())),
+			"-driversPath", maker.volmanDriverPath(),
 			"-transport", "tcp-json",
 			"-uniqueVolumeIds",
 		),
@@ -1134,12 +1509,18 @@ func (maker commonComponentMaker) VolmanDriver(logger lager.Logger) (ifrit.Runne
 	})
 
 	client, err := driverhttp.NewRemoteClient("http://"+maker.addresses.FakeVolmanDriver, nil)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return fakeDriverRunner, client, nil
+}
 
-	return fakeDriverRunner, client
+func (maker commonComponentFactory) volmanDriverPath() string {
+	return path.Join(maker.volmanDriverConfigDir, fmt.Sprintf("node-%d", maker.parallelProcess))
 }
 
-func (maker commonComponentMaker) locketClientConfig() locket.ClientLocketConfig {
+func (maker commonComponentFactory) locketClientConfig() locket.ClientLocketConfig {
 	return locket.ClientLocketConfig{
 		LocketAddress:        maker.addresses.Locket,
 		LocketCACertFile:     maker.locketSSL.CACert,
@@ -1148,15 +1529,39 @@ func (maker commonComponentMaker) locketClientConfig() locket.ClientLocketConfig
 	}
 }
 
-type v1ComponentMaker struct {
-	commonComponentMaker
+type v1ComponentFactory struct {
+	commonComponentFactory
+}
+
+type v0ComponentFactory struct {
+	commonComponentFactory
+}
+
+// WithReadiness returns a factory that shares the state of maker, but whose
+// WithReadinessCheck waits for components according to mode.
+func (maker v1ComponentFactory) WithReadiness(mode ReadinessMode) ComponentFactory {
+	maker.readiness = mode
+	return maker
 }
 
-type v0ComponentMaker struct {
-	commonComponentMaker
+// WithReadiness returns a factory that shares the state of maker, but whose
+// WithReadinessCheck waits for components according to mode.
+func (maker v0ComponentFactory) WithReadiness(mode ReadinessMode) ComponentFactory {
+	maker.readiness = mode
+	return maker
 }
 
-func (maker v0ComponentMaker) Auctioneer(modifyConfigFuncs ...func(*auctioneerconfig.AuctioneerConfig)) *ginkgomon.Runner {
+func (maker v0ComponentFactory) Auctioneer(modifyConfigFuncs ...func(*auctioneerconfig.AuctioneerConfig)) (*ginkgomon.Runner, error) {
+	return maker.AuctioneerN(0, modifyConfigFuncs...)
+}
+
+// AuctioneerN only supports a single auctioneer, as v0 auctioneers do not
+// take their lock from locket.
+func (maker v0ComponentFactory) AuctioneerN(n int, modifyConfigFuncs ...func(*auctioneerconfig.AuctioneerConfig)) (*ginkgomon.Runner, error) {
+	if n != 0 {
+		return nil, fmt.Errorf("v0 auctioneers do not support multiple instances, got instance %d", n)
+	}
+
 	cfg := auctioneerconfig.AuctioneerConfig{
 		BBSAddress:        maker.BBSURL(),
 		BBSCACertFile:     maker.bbsSSL.CACert,
@@ -1177,6 +1582,7 @@ func (maker v0ComponentMaker) Auctioneer(modifyConfigFuncs ...func(*auctioneerco
 	for _, f := range modifyConfigFuncs {
 		f(&cfg)
 	}
+	maker.recordConfig("auctioneer", cfg)
 
 	args := []string{
 		"-bbsAddress", cfg.BBSAddress,
@@ -1192,7 +1598,7 @@ func (maker v0ComponentMaker) Auctioneer(modifyConfigFuncs ...func(*auctioneerco
 		"-startingContainerWeight", strconv.FormatFloat(cfg.StartingContainerWeight, 'f', -1, 64),
 	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.newRunner(ginkgomon.Config{
 		Name:              "auctioneer",
 		AnsiColorCode:     "35m",
 		StartCheck:        `"auctioneer.started"`,
@@ -1201,10 +1607,10 @@ func (maker v0ComponentMaker) Auctioneer(modifyConfigFuncs ...func(*auctioneerco
 			maker.artifacts.Executables["auctioneer"],
 			args...,
 		),
-	})
+	}), nil
 }
 
-func (maker v0ComponentMaker) RouteEmitter(modifyConfigFuncs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner {
+func (maker v0ComponentFactory) RouteEmitter(modifyConfigFuncs ...func(config *routeemitterconfig.RouteEmitterConfig)) (*ginkgomon.Runner, error) {
 	cfg := routeemitterconfig.RouteEmitterConfig{
 		NATSAddresses:     maker.addresses.NATS,
 		BBSAddress:        maker.BBSURL(),
@@ -1222,8 +1628,9 @@ func (maker v0ComponentMaker) RouteEmitter(modifyConfigFuncs ...func(config *rou
 	for _, f := range modifyConfigFuncs {
 		f(&cfg)
 	}
+	maker.recordConfig("route-emitter", cfg)
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.newRunner(ginkgomon.Config{
 		Name:              "route-emitter",
 		AnsiColorCode:     "36m",
 		StartCheck:        `"route-emitter.started"`,
@@ -1240,13 +1647,16 @@ func (maker v0ComponentMaker) RouteEmitter(modifyConfigFuncs ...func(config *rou
 				"-bbsCACert", cfg.BBSCACertFile,
 			}...,
 		),
-	})
+	}), nil
 }
 
-func (maker v0ComponentMaker) FileServer() (ifrit.Runner, string) {
-	servedFilesDir := TempDirWithParent(maker.tmpDir, fmt.Sprintf("file-server-files-%d-", GinkgoParallelProcess()))
+func (maker v0ComponentFactory) FileServer() (ifrit.Runner, string, error) {
+	servedFilesDir, err := NewTempDirWithParent(maker.tmpDir, fmt.Sprintf("file-server-files-%d-", maker.parallelProcess))
+	if err != nil {
+		return nil, "", err
+	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.newRunner(ginkgomon.Config{
 		Name:              "file-server",
 		AnsiColorCode:     "92m",
 		StartCheck:        `"file-server.ready"`,
@@ -1260,15 +1670,28 @@ func (maker v0ComponentMaker) FileServer() (ifrit.Runner, string) {
 			}...,
 		),
 		Cleanup: func() {
-			err := os.RemoveAll(servedFilesDir)
-			Expect(err).NotTo(HaveOccurred())
+			// #nosec G104 - the factory's temporary directory is removed on teardown anyway
+			os.RemoveAll(servedFilesDir)
 		},
-	}), servedFilesDir
+	}), servedFilesDir, nil
 }
 
-func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) *ginkgomon.Runner {
+func (maker v0ComponentFactory) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) (*ginkgomon.Runner, error) {
+	return maker.BBSN(0, modifyConfigFuncs...)
+}
+
+func (maker v0ComponentFactory) BBSN(n int, modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) (*ginkgomon.Runner, error) {
+	if n != 0 {
+		return nil, fmt.Errorf("v0 bbses do not support multiple instances, got instance %d", n)
+	}
+
+	instance, err := maker.BBSInstance(n)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := bbsconfig.BBSConfig{
-		AdvertiseURL:             maker.BBSURL(),
+		AdvertiseURL:             instance.URL(),
 		AuctioneerAddress:        "http://" + maker.addresses.Auctioneer,
 		CaFile:                   maker.bbsSSL.CACert,
 		CertFile:                 maker.bbsSSL.ServerCert,
@@ -1279,8 +1702,8 @@ func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 			ActiveKeyLabel: "secure-key-1",
 			EncryptionKeys: map[string]string{"secure-key-1": "secure-passphrase"},
 		},
-		HealthAddress: maker.addresses.Health,
-		ListenAddress: maker.addresses.BBS,
+		HealthAddress: instance.HealthAddress,
+		ListenAddress: instance.ListenAddress,
 		LagerConfig: lagerflags.LagerConfig{
 			LogLevel:   "debug",
 			TimeFormat: lagerflags.FormatRFC3339,
@@ -1293,6 +1716,7 @@ func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 	for _, f := range modifyConfigFuncs {
 		f(&cfg)
 	}
+	maker.recordConfig(bbsRunnerName(n), cfg)
 
 	akl := cfg.ActiveKeyLabel
 	encryptionKey := fmt.Sprintf("%s:%s", akl, cfg.EncryptionKeys[akl])
@@ -1316,8 +1740,8 @@ func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 		"-requireSSL",
 	}
 
-	return ginkgomon.New(ginkgomon.Config{
-		Name:              "bbs",
+	bbsRunner := maker.newRunner(ginkgomon.Config{
+		Name:              bbsRunnerName(n),
 		AnsiColorCode:     "32m",
 		StartCheck:        "bbs.started",
 		StartCheckTimeout: maker.startCheckTimeout,
@@ -1326,22 +1750,36 @@ func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 			args...,
 		),
 	})
+
+	maker.registerHealthCheck(bbsRunner, httpHealthCheck("http://"+cfg.HealthAddress+"/ping"))
+	return bbsRunner, nil
 }
 
-func (maker v0ComponentMaker) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+func (maker v0ComponentFactory) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) (*ginkgomon.Runner, error) {
 	return maker.RepN(0, modifyConfigFuncs...)
 }
 
-func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+func (maker v0ComponentFactory) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) (*ginkgomon.Runner, error) {
+	err := checkRepIndex(n)
+	if err != nil {
+		return nil, err
+	}
+
 	host, portString, err := net.SplitHostPort(maker.addresses.Rep)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 	port, err := strconv.Atoi(portString)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
 	name := "rep-" + strconv.Itoa(n)
 
-	executorTempDir := TempDirWithParent(maker.tmpDir, "executor")
-	cachePath := TempDirWithParent(executorTempDir, "cache")
+	executorTempDir, cachePath, err := maker.executorDirs()
+	if err != nil {
+		return nil, err
+	}
 
 	cfg := repconfig.RepConfig{
 		SessionName:               name,
@@ -1352,7 +1790,7 @@ func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 		CaCertFile:                maker.repSSL.CACert,
 		ServerCertFile:            maker.repSSL.ServerCert,
 		ServerKeyFile:             maker.repSSL.ServerKey,
-		CellID:                    "cell_z1" + "-" + strconv.Itoa(n) + "-" + strconv.Itoa(GinkgoParallelProcess()),
+		CellID:                    "cell_z1" + "-" + strconv.Itoa(n) + "-" + strconv.Itoa(maker.parallelProcess),
 		Zone:                      "z1",
 		EvacuationPollingInterval: durationjson.Duration(1 * time.Second),
 		EvacuationTimeout:         durationjson.Duration(10 * time.Second),
@@ -1364,7 +1802,7 @@ func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 			GardenHealthcheckProcessUser: "vcap",
 			GardenNetwork:                "tcp",
 			TempDir:                      executorTempDir,
-			VolmanDriverPaths:            path.Join(maker.volmanDriverConfigDir, fmt.Sprintf("node-%d", GinkgoParallelProcess())),
+			VolmanDriverPaths:            maker.volmanDriverPath(),
 		},
 		ListenAddr:          fmt.Sprintf("%s:%d", host, offsetPort(port, n)),
 		ListenAddrSecurable: fmt.Sprintf("%s:%d", host, offsetPort(port+100, n)),
@@ -1394,6 +1832,7 @@ func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 	for _, f := range modifyConfigFuncs {
 		f(&cfg)
 	}
+	maker.recordConfig(name, cfg)
 
 	args := []string{
 		"-sessionName", cfg.SessionName,
@@ -1433,7 +1872,7 @@ func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 		args = append(args, "-preloadedRootFS", fmt.Sprintf("%s:%s", rootfs.Name, rootfs.Path))
 	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	repRunner := maker.newRunner(ginkgomon.Config{
 		Name:          name,
 		AnsiColorCode: "33m",
 		StartCheck:    `"` + name + `.started"`,
@@ -1445,9 +1884,30 @@ func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 			args...,
 		),
 	})
+
+	maker.registerHealthCheck(repRunner, repHealthCheck(cfg.ListenAddr, maker.repSSL))
+	return repRunner, nil
 }
 
-func (maker v1ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) *ginkgomon.Runner {
+func (maker v1ComponentFactory) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) (*ginkgomon.Runner, error) {
+	return maker.BBSN(0, modifyConfigFuncs...)
+}
+
+// BBSN returns a runner for the nth BBS instance. All instances share the
+// database and locket and compete for the BBS lock; only the holder of the
+// lock reports that it has started, so start the standby instances with
+// ifrit.Background rather than ginkgomon.Invoke.
+//
+// The rep, auctioneer, route-emitter and the other BBS clients the factory
+// makes are only given BBSURL(), the address of instance 0. They do not fail
+// over when another instance takes the lock, so connect to a standby with a
+// client of its own, at BBSInstance(n).URL().
+func (maker v1ComponentFactory) BBSN(n int, modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) (*ginkgomon.Runner, error) {
+	instance, err := maker.BBSInstance(n)
+	if err != nil {
+		return nil, err
+	}
+
 	config := bbsconfig.BBSConfig{
 		SessionName:                 "bbs",
 		CommunicationTimeout:        durationjson.Duration(10 * time.Second),
@@ -1466,7 +1926,7 @@ func (maker v1ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 		MaxIdleDatabaseConnections:  200,
 		RepClientSessionCacheSize:   0,
 
-		AdvertiseURL: maker.BBSURL(),
+		AdvertiseURL: instance.URL(),
 		EncryptionConfig: encryption.EncryptionConfig{
 			ActiveKeyLabel: "secure-key-1",
 			EncryptionKeys: map[string]string{
@@ -1478,8 +1938,8 @@ func (maker v1ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 			TimeFormat: lagerflags.FormatRFC3339,
 		},
 		AuctioneerAddress:        "https://" + maker.addresses.Auctioneer,
-		ListenAddress:            maker.addresses.BBS,
-		HealthAddress:            maker.addresses.Health,
+		ListenAddress:            instance.ListenAddress,
+		HealthAddress:            instance.HealthAddress,
 		RequireSSL:               true,
 		CertFile:                 maker.bbsSSL.ServerCert,
 		KeyFile:                  maker.bbsSSL.ServerKey,
@@ -1495,33 +1955,49 @@ func (maker v1ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 		AuctioneerRequireTLS:     true,
 		SQLCACertFile:            maker.sqlCACertFile,
 		ClientLocketConfig:       maker.locketClientConfig(),
-		UUID:                     "bbs-inigo-lock-owner",
+		UUID:                     instance.UUID,
 	}
 
 	for _, modifyConfig := range modifyConfigFuncs {
 		modifyConfig(&config)
 	}
+	maker.recordConfig(bbsRunnerName(n), config)
 
 	runner := bbsrunner.New(maker.artifacts.Executables["bbs"], config)
+	runner.Name = bbsRunnerName(n)
 	runner.AnsiColorCode = "32m"
 	runner.StartCheckTimeout = maker.startCheckTimeout
-	return runner
+	maker.recordRunner(runner)
+
+	maker.registerHealthCheck(runner, httpHealthCheck("http://"+config.HealthAddress+"/ping"))
+	return runner, nil
 }
 
-func (maker v1ComponentMaker) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+func (maker v1ComponentFactory) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) (*ginkgomon.Runner, error) {
 	return maker.RepN(0, modifyConfigFuncs...)
 }
 
-func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+func (maker v1ComponentFactory) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) (*ginkgomon.Runner, error) {
+	err := checkRepIndex(n)
+	if err != nil {
+		return nil, err
+	}
+
 	host, portString, err := net.SplitHostPort(maker.addresses.Rep)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 	port, err := strconv.Atoi(portString)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
 	name := "rep-" + strconv.Itoa(n)
 
-	executorTempDir := TempDirWithParent(maker.tmpDir, "executor")
-	cachePath := TempDirWithParent(executorTempDir, "cache")
+	executorTempDir, cachePath, err := maker.executorDirs()
+	if err != nil {
+		return nil, err
+	}
 
 	repConfig := repconfig.RepConfig{
 		AdvertiseDomain:           "cell.service.cf.internal",
@@ -1536,7 +2012,7 @@ func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 		BBSClientKeyFile:          maker.bbsSSL.ClientKey,
 		BBSCACertFile:             maker.bbsSSL.CACert,
 		ListenAddr:                fmt.Sprintf("%s:%d", host, offsetPort(port, n)),
-		CellID:                    "the-cell-id-" + strconv.Itoa(GinkgoParallelProcess()) + "-" + strconv.Itoa(n),
+		CellID:                    "the-cell-id-" + strconv.Itoa(maker.parallelProcess) + "-" + strconv.Itoa(n),
 		PollingInterval:           durationjson.Duration(1 * time.Second),
 		ReportInterval:            durationjson.Duration(1 * time.Minute),
 		EvacuationPollingInterval: durationjson.Duration(1 * time.Second),
@@ -1586,7 +2062,7 @@ func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 			CachePath:                     cachePath,
 			TempDir:                       executorTempDir,
 			GardenHealthcheckProcessUser:  "vcap",
-			VolmanDriverPaths:             path.Join(maker.volmanDriverConfigDir, fmt.Sprintf("node-%d", GinkgoParallelProcess())),
+			VolmanDriverPaths:             maker.volmanDriverPath(),
 			ContainerOwnerName:            "executor-" + strconv.Itoa(n),
 			HealthCheckContainerOwnerName: "executor-health-check-" + strconv.Itoa(n),
 			PathToTLSCert:                 maker.repSSL.ServerCert,
@@ -1611,16 +2087,21 @@ func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 	for _, modifyConfig := range modifyConfigFuncs {
 		modifyConfig(&repConfig)
 	}
+	maker.recordConfig(name, repConfig)
 
-	configFile, err := os.CreateTemp(TempDirWithParent(maker.tmpDir, "rep-config"), "rep-config")
-	Expect(err).NotTo(HaveOccurred())
+	configFile, err := maker.createConfigFile("rep-config", "rep-config")
+	if err != nil {
+		return nil, err
+	}
 
 	defer configFile.Close()
 
 	err = json.NewEncoder(configFile).Encode(repConfig)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	repRunner := maker.newRunner(ginkgomon.Config{
 		Name:          name,
 		AnsiColorCode: "33m",
 		StartCheck:    `"` + name + `.started"`,
@@ -1631,9 +2112,21 @@ func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 			maker.artifacts.Executables["rep"],
 			"-config", configFile.Name()),
 	})
+
+	maker.registerHealthCheck(repRunner, repHealthCheck(repConfig.ListenAddr, maker.repSSL))
+	return repRunner, nil
+}
+
+func (maker v1ComponentFactory) Auctioneer(modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) (*ginkgomon.Runner, error) {
+	return maker.AuctioneerN(0, modifyConfigFuncs...)
 }
 
-func (maker v1ComponentMaker) Auctioneer(modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) *ginkgomon.Runner {
+// AuctioneerN returns a runner for the nth auctioneer. All instances compete
+// for the auctioneer lock in locket and share the auctioneer address, which
+// only the lock holder listens on, so the BBS always reaches the active
+// auctioneer. Only the holder reports that it has started, so start the
+// standby instances with ifrit.Background rather than ginkgomon.Invoke.
+func (maker v1ComponentFactory) AuctioneerN(n int, modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) (*ginkgomon.Runner, error) {
 	auctioneerConfig := auctioneerconfig.AuctioneerConfig{
 		AuctionRunnerWorkers:          1000,
 		CellStateTimeout:              durationjson.Duration(1 * time.Second),
@@ -1660,21 +2153,27 @@ func (maker v1ComponentMaker) Auctioneer(modifyConfigFuncs ...func(cfg *auctione
 			TimeFormat: lagerflags.FormatRFC3339,
 		},
 		ClientLocketConfig: maker.locketClientConfig(),
-		UUID:               "auctioneer-inigo-lock-owner",
+		UUID:               auctioneerUUID(n),
 	}
 
 	for _, modifyConfig := range modifyConfigFuncs {
 		modifyConfig(&auctioneerConfig)
 	}
+	maker.recordConfig(auctioneerRunnerName(n), auctioneerConfig)
 
-	configFile, err := os.CreateTemp(TempDirWithParent(maker.tmpDir, "auctioneer-"), "auctioneer-config-")
-	Expect(err).NotTo(HaveOccurred())
+	configFile, err := maker.createConfigFile("auctioneer-", "auctioneer-config-")
+	if err != nil {
+		return nil, err
+	}
+	defer configFile.Close()
 
 	err = json.NewEncoder(configFile).Encode(auctioneerConfig)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return nil, err
+	}
 
-	return ginkgomon.New(ginkgomon.Config{
-		Name:              "auctioneer",
+	return maker.newRunner(ginkgomon.Config{
+		Name:              auctioneerRunnerName(n),
 		AnsiColorCode:     "35m",
 		StartCheck:        `"auctioneer.started"`,
 		StartCheckTimeout: maker.startCheckTimeout,
@@ -1682,54 +2181,119 @@ func (maker v1ComponentMaker) Auctioneer(modifyConfigFuncs ...func(cfg *auctione
 			maker.artifacts.Executables["auctioneer"],
 			"-config", configFile.Name(),
 		),
-	})
+	}), nil
 }
 
-func (maker v1ComponentMaker) RouteEmitter(modifyConfigFuncs ...func(config *routeemitterconfig.RouteEmitterConfig)) *ginkgomon.Runner {
+func (maker v1ComponentFactory) RouteEmitter(modifyConfigFuncs ...func(config *routeemitterconfig.RouteEmitterConfig)) (*ginkgomon.Runner, error) {
 	return maker.RouteEmitterN(0, modifyConfigFuncs...)
 }
 
-func (blc *BuiltLifecycles) BuildLifecycles(lifeCycle string, tmpDir string) {
+// Build compiles the given lifecycle through buildCache into a tarball in a
+// new directory inside tmpDir and records its path. The output of tar is
+// written to output.
+func (blc *BuiltLifecycles) Build(buildCache BuildCache, lifeCycle string, tmpDir string, output io.Writer) error {
 	lifeCyclePath := filepath.Join("code.cloudfoundry.org", lifeCycle)
 
-	builderPath, err := gexec.Build(filepath.Join(lifeCyclePath, "builder"), "-race")
-	Expect(err).NotTo(HaveOccurred())
+	lifecycleDir, err := NewTempDirWithParent(tmpDir, lifeCycle)
+	if err != nil {
+		return err
+	}
+
+	err = buildCache.BuildTo(filepath.Join(lifecycleDir, "builder"), filepath.Join(lifeCyclePath, "builder"), "-race")
+	if err != nil {
+		return err
+	}
 
-	launcherPath, err := gexec.Build(filepath.Join(lifeCyclePath, "launcher"), "-race")
-	Expect(err).NotTo(HaveOccurred())
+	err = buildCache.BuildTo(filepath.Join(lifecycleDir, "launcher"), filepath.Join(lifeCyclePath, "launcher"), "-race")
+	if err != nil {
+		return err
+	}
 
-	healthcheckPath, err := gexec.Build("code.cloudfoundry.org/healthcheck/cmd/healthcheck", "-race")
-	Expect(err).NotTo(HaveOccurred())
+	err = buildCache.BuildTo(filepath.Join(lifecycleDir, "healthcheck"), "code.cloudfoundry.org/healthcheck/cmd/healthcheck", "-race")
+	if err != nil {
+		return err
+	}
 
 	err = os.Setenv("CGO_ENABLED", "0")
-	Expect(err).ToNot(HaveOccurred())
+	if err != nil {
+		return err
+	}
 
-	diegoSSHPath, err := gexec.Build("code.cloudfoundry.org/diego-ssh/cmd/sshd", "-a", "-installsuffix", "static")
+	err = buildCache.BuildTo(filepath.Join(lifecycleDir, "diego-sshd"), "code.cloudfoundry.org/diego-ssh/cmd/sshd", "-a", "-installsuffix", "static")
 	os.Unsetenv("CGO_ENABLED")
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("tar", "-czf", "lifecycle.tar.gz", "builder", "launcher", "healthcheck", "diego-sshd")
+	cmd.Stderr = output
+	cmd.Stdout = output
+	cmd.Dir = lifecycleDir
+	err = cmd.Run()
+	if err != nil {
+		return err
+	}
+
+	(*blc)[lifeCycle] = filepath.Join(lifecycleDir, LifecycleFilename)
+	return nil
+}
+
+// executorDirs creates the temporary and download cache directories used by
+// the executor inside a rep.
+func (maker commonComponentFactory) executorDirs() (string, string, error) {
+	executorTempDir, err := NewTempDirWithParent(maker.tmpDir, "executor")
+	if err != nil {
+		return "", "", err
+	}
 
-	lifecycleDir := TempDirWithParent(tmpDir, lifeCycle)
+	cachePath, err := NewTempDirWithParent(executorTempDir, "cache")
+	if err != nil {
+		return "", "", err
+	}
 
-	err = os.Rename(builderPath, filepath.Join(lifecycleDir, "builder"))
-	Expect(err).NotTo(HaveOccurred())
+	return executorTempDir, cachePath, nil
+}
 
-	err = os.Rename(healthcheckPath, filepath.Join(lifecycleDir, "healthcheck"))
-	Expect(err).NotTo(HaveOccurred())
+// pingTimeout is how long to wait for the SQL server to accept connections.
+const pingTimeout = time.Minute
 
-	err = os.Rename(launcherPath, filepath.Join(lifecycleDir, "launcher"))
-	Expect(err).NotTo(HaveOccurred())
+// retryFor calls f until it succeeds or the timeout has elapsed, in which case
+// the last error is returned.
+func retryFor(timeout time.Duration, f func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := f()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
 
-	err = os.Rename(diegoSSHPath, filepath.Join(lifecycleDir, "diego-sshd"))
-	Expect(err).NotTo(HaveOccurred())
+// addressPort returns the port of a host:port address.
+func addressPort(address string) (int, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, err
+	}
 
-	cmd := exec.Command("tar", "-czf", "lifecycle.tar.gz", "builder", "launcher", "healthcheck", "diego-sshd")
-	cmd.Stderr = GinkgoWriter
-	cmd.Stdout = GinkgoWriter
-	cmd.Dir = lifecycleDir
-	err = cmd.Run()
-	Expect(err).NotTo(HaveOccurred())
+	return strconv.Atoi(port)
+}
 
-	(*blc)[lifeCycle] = filepath.Join(lifecycleDir, LifecycleFilename)
+// bbsRunnerName keeps the name of the first BBS unchanged so that its output
+// is prefixed the same way it always has been.
+func bbsRunnerName(n int) string {
+	if n == 0 {
+		return "bbs"
+	}
+	return "bbs-" + strconv.Itoa(n)
+}
+
+func auctioneerRunnerName(n int) string {
+	if n == 0 {
+		return "auctioneer"
+	}
+	return "auctioneer-" + strconv.Itoa(n)
 }
 
 // offsetPort retuns a new port offest by a given number in such a way
@@ -0,0 +1,166 @@
+package world
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redactedValue replaces the values of secret config fields.
+const redactedValue = "[REDACTED]"
+
+// secretFields are the substrings of the JSON field names whose values are
+// redacted. Paths to certificates and keys are not secret and are kept.
+var secretFields = []string{
+	"password",
+	"passphrase",
+	"secret",
+	"token",
+	"encryption_keys",
+	"connection_string",
+	"private_key",
+	"host_key",
+	"credential",
+}
+
+// secretKeys are JSON field names whose values are redacted when they match
+// a whole key, as they are too short to match within other names.
+var secretKeys = []string{
+	"pass",
+}
+
+// ComponentConfig is the final config a runner was built with, after every
+// modify func was applied.
+type ComponentConfig struct {
+	Name    string
+	BuiltAt time.Time
+	Config  interface{}
+}
+
+// componentConfigs records the config of every runner built by a factory. It
+// is shared by all copies of the factory.
+type componentConfigs struct {
+	mutex   sync.Mutex
+	configs []ComponentConfig
+}
+
+func newComponentConfigs() *componentConfigs {
+	return &componentConfigs{}
+}
+
+func (maker commonComponentFactory) recordConfig(name string, config interface{}) {
+	maker.configs.mutex.Lock()
+	defer maker.configs.mutex.Unlock()
+
+	maker.configs.configs = append(maker.configs.configs, ComponentConfig{
+		Name:    name,
+		BuiltAt: time.Now(),
+		Config:  config,
+	})
+}
+
+// Configs returns the configs of the runners built at or after since, in the
+// order they were built.
+func (maker commonComponentFactory) Configs(since time.Time) []ComponentConfig {
+	maker.configs.mutex.Lock()
+	defer maker.configs.mutex.Unlock()
+
+	configs := []ComponentConfig{}
+	for _, config := range maker.configs.configs {
+		if !config.BuiltAt.Before(since) {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
+
+// ClearConfigs forgets the configs of every runner built so far.
+func (maker commonComponentFactory) ClearConfigs() {
+	maker.configs.mutex.Lock()
+	defer maker.configs.mutex.Unlock()
+	maker.configs.configs = nil
+}
+
+// RedactConfig returns config as indented JSON with the values of secret
+// fields replaced.
+func RedactConfig(config interface{}) ([]byte, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields interface{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(redact(fields), "", "  ")
+}
+
+func redact(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if isSecretField(key) {
+				value[key] = redactedValue
+			} else {
+				value[key] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i, element := range value {
+			value[i] = redact(element)
+		}
+	}
+	return value
+}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretKeys {
+		if name == secret {
+			return true
+		}
+	}
+	for _, secret := range secretFields {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// DumpConfigs writes each config, redacted, to <name>.json in dir. Configs
+// that share a name are numbered in the order they were built.
+func DumpConfigs(configs []ComponentConfig, dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]int{}
+	for _, config := range configs {
+		seen[config.Name]++
+
+		name := config.Name
+		if seen[config.Name] > 1 {
+			name += "-" + strconv.Itoa(seen[config.Name])
+		}
+
+		data, err := RedactConfig(config.Config)
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(filepath.Join(dir, name+".json"), data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package world
+
+import (
+	"code.cloudfoundry.org/inigo/helpers/fakegarden"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// FakeGarden returns an in-process Garden server listening on the Garden
+// address, in place of the Guardian started by Garden. It needs neither root
+// privileges nor runc and grootfs, so specs that only exercise scheduling by
+// the BBS, auctioneer and rep can run it on any machine:
+//
+//	gardenServer := componentMaker.FakeGarden()
+//	gardenServer.FailCreate(errors.New("out of space"))
+//	ginkgomon.Invoke(grouper.NewParallel(os.Kill, grouper.Members{
+//		{Name: "garden", Runner: gardenServer},
+//		{Name: "rep", Runner: componentMaker.Rep()},
+//	}))
+//
+// Processes run in its containers are not executed; see
+// fakegarden.ProcessScript.
+func (maker commonComponentFactory) FakeGarden() *fakegarden.Garden {
+	logger := lager.NewLogger("fake-garden")
+	logger.RegisterSink(lager.NewWriterSink(maker.output, lager.INFO))
+
+	return fakegarden.New(maker.addresses.Garden, logger)
+}
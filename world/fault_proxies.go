@@ -0,0 +1,25 @@
+package world
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/inigo/helpers/faultproxy"
+)
+
+// FaultProxy returns a proxy to target on a port claimed from the port
+// allocator. Point a component at the proxy's Address instead of target, for
+// example with a modify-config func setting the rep's BBS address, and run the
+// proxy to place it between the two:
+//
+//	proxy := componentMaker.FaultProxy(componentMaker.Addresses().BBS)
+//	rep := componentMaker.Rep(func(cfg *repconfig.RepConfig) {
+//		cfg.BBSAddress = "https://" + proxy.Address()
+//	})
+func (maker commonComponentFactory) FaultProxy(target string) (*faultproxy.Proxy, error) {
+	port, err := claimFreePorts(maker.portAllocator, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return faultproxy.New(fmt.Sprintf("127.0.0.1:%d", port), target), nil
+}
@@ -0,0 +1,102 @@
+package world
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/v3"
+	"code.cloudfoundry.org/locket"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"code.cloudfoundry.org/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// The locket keys that instances of the same component compete for.
+const (
+	BBSLockKey        = "bbs"
+	AuctioneerLockKey = "auctioneer"
+)
+
+// LockOwner returns the owner of the lock held in locket under key, such as
+// BBSLockKey, or an error if nobody holds it.
+func (maker commonComponentFactory) LockOwner(logger lager.Logger, key string) (string, error) {
+	locketClient, err := maker.locketClient.get()
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := locketClient.Fetch(ctx, &locketmodels.FetchRequest{Key: key})
+	if err != nil {
+		logger.Debug("failed-to-fetch-lock", lager.Data{"key": key, "error": err.Error()})
+		return "", err
+	}
+
+	return resp.Resource.Owner, nil
+}
+
+// locketClient is the connection of a factory to locket, shared by all
+// copies of the factory. gRPC connects in the background, so the client can
+// be made before locket runs.
+type locketClient struct {
+	config locket.ClientLocketConfig
+
+	mutex  sync.Mutex
+	conn   *grpc.ClientConn
+	client locketmodels.LocketClient
+}
+
+func newLocketClient(config locket.ClientLocketConfig) (*locketClient, error) {
+	c := &locketClient{config: config}
+	_, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *locketClient) get() (locketmodels.LocketClient, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	tlsConfig, err := tlsconfig.Build(
+		tlsconfig.WithInternalServiceDefaults(),
+		tlsconfig.WithIdentityFromFile(c.config.LocketClientCertFile, c.config.LocketClientKeyFile),
+	).Client(tlsconfig.WithAuthorityFromFile(c.config.LocketCACertFile))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(c.config.LocketAddress, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return nil, err
+	}
+
+	c.conn = conn
+	c.client = locketmodels.NewLocketClient(conn)
+	return c.client, nil
+}
+
+// close closes the connection. The next use of the client connects again,
+// with the certificates then on disk.
+func (c *locketClient) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+	c.client = nil
+	return err
+}
@@ -0,0 +1,261 @@
+package world
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/tlsconfig"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// ReadinessMode selects how a component is considered started.
+type ReadinessMode string
+
+const (
+	// LogReadiness waits for the component to log its start message.
+	LogReadiness ReadinessMode = "log"
+
+	// HealthReadiness polls the component's health endpoint. Components
+	// without one fall back to LogReadiness.
+	HealthReadiness ReadinessMode = "health"
+)
+
+// healthCheckInterval is how often a HealthReadiness runner polls.
+const healthCheckInterval = 100 * time.Millisecond
+
+// HealthCheck returns nil once the component it checks is ready to serve.
+type HealthCheck func() error
+
+func parseReadinessMode(mode string) (ReadinessMode, error) {
+	switch ReadinessMode(mode) {
+	case "", LogReadiness:
+		return LogReadiness, nil
+	case HealthReadiness:
+		return HealthReadiness, nil
+	default:
+		return "", fmt.Errorf("unknown readiness mode %q, expected %q or %q", mode, LogReadiness, HealthReadiness)
+	}
+}
+
+// healthChecks maps the runners built by a factory to the health checks of
+// their components. It is shared by all copies of the factory.
+type healthChecks struct {
+	mutex  sync.Mutex
+	checks map[ifrit.Runner]HealthCheck
+}
+
+func newHealthChecks() *healthChecks {
+	return &healthChecks{checks: map[ifrit.Runner]HealthCheck{}}
+}
+
+func (h *healthChecks) register(runner ifrit.Runner, check HealthCheck) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checks[runner] = check
+}
+
+// take returns the health check of runner and forgets it, as a runner is
+// only wrapped once.
+func (h *healthChecks) take(runner ifrit.Runner) (HealthCheck, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	check, ok := h.checks[runner]
+	delete(h.checks, runner)
+	return check, ok
+}
+
+// WithReadinessCheck returns a runner that reports runner as ready according
+// to the factory's readiness mode. In LogReadiness mode, or if the component
+// has no health endpoint, runner is returned unchanged. Otherwise runner is
+// run by the returned runner, with its start check cleared, so that it stays
+// the handle to the component's output.
+func (maker commonComponentFactory) WithReadinessCheck(runner ifrit.Runner) ifrit.Runner {
+	if maker.readiness != HealthReadiness {
+		return runner
+	}
+
+	ginkgomonRunner, ok := runner.(*ginkgomon.Runner)
+	if !ok {
+		return runner
+	}
+
+	check, ok := maker.healthChecks.take(runner)
+	if !ok {
+		return runner
+	}
+
+	return &healthCheckedRunner{runner: ginkgomonRunner, check: check}
+}
+
+func (maker commonComponentFactory) registerHealthCheck(runner ifrit.Runner, check HealthCheck) {
+	if maker.readiness != HealthReadiness {
+		return
+	}
+	maker.healthChecks.register(runner, check)
+}
+
+// healthCheckedRunner runs a ginkgomon runner without its log start check
+// and becomes ready once the health check passes. The ginkgomon runner is
+// the one that runs, so that its output can be read as usual.
+type healthCheckedRunner struct {
+	runner *ginkgomon.Runner
+	check  HealthCheck
+}
+
+func (r *healthCheckedRunner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	timeout := r.runner.StartCheckTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	r.runner.StartCheck = ""
+	process := ifrit.Background(r.runner)
+
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	tick := ticker.C
+	startTimeout := time.After(timeout)
+
+	var lastErr error
+	for {
+		select {
+		case <-tick:
+			lastErr = r.check()
+			if lastErr == nil {
+				tick = nil
+				startTimeout = nil
+				close(ready)
+			}
+
+		case <-startTimeout:
+			process.Signal(os.Kill)
+			<-process.Wait()
+			return fmt.Errorf("%s was not healthy within %s: %v", r.runner.Name, timeout, lastErr)
+
+		case signal := <-signals:
+			process.Signal(signal)
+
+		case err := <-process.Wait():
+			return err
+		}
+	}
+}
+
+func httpHealthCheck(url string) HealthCheck {
+	client := &http.Client{Timeout: time.Second}
+
+	return func() error {
+		return checkHTTPHealth(client, url)
+	}
+}
+
+func checkHTTPHealth(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// httpsHealthCheck checks url with certFile and keyFile as the client
+// identity, trusting caFile. The files are read on every check, so that the
+// check keeps working after the certificates are rotated.
+func httpsHealthCheck(url, certFile, keyFile, caFile string) HealthCheck {
+	return func() error {
+		tlsConfig, err := tlsconfig.Build(
+			tlsconfig.WithInternalServiceDefaults(),
+			tlsconfig.WithIdentityFromFile(certFile, keyFile),
+		).Client(tlsconfig.WithAuthorityFromFile(caFile))
+		if err != nil {
+			return err
+		}
+
+		client := &http.Client{
+			Timeout:   time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		}
+		defer client.CloseIdleConnections()
+
+		return checkHTTPHealth(client, url)
+	}
+}
+
+// repHealthCheck pings the mTLS server of a rep listening on listenAddr, as
+// the rep's peers do, with the rep's server certificate as the client
+// identity.
+func repHealthCheck(listenAddr string, sslConfig SSLConfig) HealthCheck {
+	return httpsHealthCheck("https://"+loopbackAddress(listenAddr)+"/ping", sslConfig.ServerCert, sslConfig.ServerKey, sslConfig.CACert)
+}
+
+// loopbackAddress replaces an unspecified host in address, such as 0.0.0.0,
+// with 127.0.0.1 so that it can be dialed.
+func loopbackAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	ip := net.ParseIP(host)
+	if host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func grpcHealthCheck(address string, sslConfig SSLConfig) HealthCheck {
+	return func() error {
+		tlsConfig, err := tlsconfig.Build(
+			tlsconfig.WithInternalServiceDefaults(),
+			tlsconfig.WithIdentityFromFile(sslConfig.ClientCert, sslConfig.ClientKey),
+		).Client(tlsconfig.WithAuthorityFromFile(sslConfig.CACert))
+		if err != nil {
+			return err
+		}
+
+		return checkGRPCHealth(address, tlsConfig)
+	}
+}
+
+func checkGRPCHealth(address string, tlsConfig *tls.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)), grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		// the server completed a TLS handshake and answered a gRPC call, which
+		// is as healthy as a server without the health service gets
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%s is %s", address, resp.Status)
+	}
+	return nil
+}
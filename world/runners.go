@@ -0,0 +1,78 @@
+package world
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+)
+
+// ComponentRunner is a runner built by a factory, whose output can be read
+// once it has run.
+type ComponentRunner struct {
+	Name    string
+	BuiltAt time.Time
+	Runner  *ginkgomon.Runner
+}
+
+// componentRunners records every ginkgomon runner built by a factory. It is
+// shared by all copies of the factory.
+type componentRunners struct {
+	mutex   sync.Mutex
+	runners []ComponentRunner
+}
+
+func newComponentRunners() *componentRunners {
+	return &componentRunners{}
+}
+
+func (r *componentRunners) record(runner *ginkgomon.Runner) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.runners = append(r.runners, ComponentRunner{
+		Name:    runner.Name,
+		BuiltAt: time.Now(),
+		Runner:  runner,
+	})
+}
+
+// newRunner builds a ginkgomon runner and records it.
+func (maker commonComponentFactory) newRunner(config ginkgomon.Config) *ginkgomon.Runner {
+	runner := ginkgomon.New(config)
+	maker.runners.record(runner)
+	return runner
+}
+
+// recordRunner records runner if it is a ginkgomon runner built elsewhere.
+func (maker commonComponentFactory) recordRunner(runner ifrit.Runner) {
+	if ginkgomonRunner, ok := runner.(*ginkgomon.Runner); ok {
+		maker.runners.record(ginkgomonRunner)
+	}
+}
+
+// Runners returns the runners built at or after since whose commands have
+// been started, in the order they were built. Their output is complete once
+// they have exited.
+func (maker commonComponentFactory) Runners(since time.Time) []ComponentRunner {
+	maker.runners.mutex.Lock()
+	defer maker.runners.mutex.Unlock()
+
+	runners := []ComponentRunner{}
+	for _, runner := range maker.runners.runners {
+		if runner.BuiltAt.Before(since) || runner.Runner.Command.Process == nil {
+			continue
+		}
+		runners = append(runners, runner)
+	}
+	return runners
+}
+
+// ClearRunners forgets every runner built so far, so that their output can be
+// freed.
+func (maker commonComponentFactory) ClearRunners() {
+	maker.runners.mutex.Lock()
+	defer maker.runners.mutex.Unlock()
+	maker.runners.runners = nil
+}
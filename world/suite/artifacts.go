@@ -0,0 +1,215 @@
+package suite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"code.cloudfoundry.org/inigo/helpers/timeline"
+	"code.cloudfoundry.org/inigo/world"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// specLogName is the log file of everything the spec writes to GinkgoWriter.
+const specLogName = "spec"
+
+// ReportConfigs attaches the redacted config of every runner built during a
+// failed spec to its report, and writes them to the spec's artifacts
+// directory. The configs are then forgotten, whether the spec failed or not.
+func (s *Suite) ReportConfigs() {
+	if s.componentMaker == nil {
+		return
+	}
+	defer s.componentMaker.ClearConfigs()
+
+	report := CurrentSpecReport()
+	if !report.Failed() {
+		return
+	}
+
+	configs := s.componentMaker.Configs(report.StartTime)
+	for _, config := range configs {
+		data, err := world.RedactConfig(config.Config)
+		Expect(err).NotTo(HaveOccurred())
+		AddReportEntry(config.Name+" config", string(data), ReportEntryVisibilityFailureOrVerbose)
+	}
+
+	specDir := specArtifactsDir(report)
+	if specDir == "" || len(configs) == 0 {
+		return
+	}
+
+	err := world.DumpConfigs(configs, filepath.Join(specDir, "configs"))
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// CaptureComponentLogs tees GinkgoWriter into spec.log in the spec's
+// artifacts directory. When the spec fails, the output of every runner the
+// ComponentMaker built during the spec is written to a log file of its own
+// next to it, the files are linked from the spec's report, and the runners'
+// output is added to the spec's timeline, which is written to the artifacts
+// directory or, if there is none, to a directory of the spec in the process's
+// temporary directory. When the spec passes the logs are removed.
+func (s *Suite) CaptureComponentLogs() {
+	s.timeline = timeline.New()
+	startTime := time.Now()
+
+	specDir := specArtifactsDir(CurrentSpecReport())
+	logsDir := ""
+	var specLog *os.File
+	if specDir != "" {
+		logsDir = filepath.Join(specDir, "logs")
+		Expect(os.MkdirAll(logsDir, 0755)).To(Succeed())
+
+		var err error
+		specLog, err = os.Create(filepath.Join(logsDir, specLogName+".log"))
+		Expect(err).NotTo(HaveOccurred())
+		GinkgoWriter.TeeTo(specLog)
+	}
+
+	// cleanups run after every AfterEach, so components stopped there are
+	// still captured
+	DeferCleanup(func() {
+		s.timeline.Stop()
+		if specLog != nil {
+			GinkgoWriter.ClearTeeWriters()
+			Expect(specLog.Close()).To(Succeed())
+		}
+
+		var runners []world.ComponentRunner
+		if s.componentMaker != nil {
+			runners = s.componentMaker.Runners(startTime)
+			defer s.componentMaker.ClearRunners()
+		}
+
+		if !CurrentSpecReport().Failed() {
+			if logsDir != "" {
+				Expect(os.RemoveAll(logsDir)).To(Succeed())
+			}
+			return
+		}
+
+		for _, runner := range runners {
+			s.timeline.AddRunner(runner.Name, runner.Runner)
+		}
+
+		if logsDir != "" {
+			paths, err := writeRunnerLogs(logsDir, runners)
+			Expect(err).NotTo(HaveOccurred())
+			for _, path := range append([]string{specLog.Name()}, paths...) {
+				name := strings.TrimSuffix(filepath.Base(path), ".log")
+				AddReportEntry(name+" log", relativeToArtifacts(path), ReportEntryVisibilityFailureOrVerbose)
+			}
+		}
+
+		timelineDir := specDir
+		if timelineDir == "" {
+			timelineDir = world.TempDirWithParent(s.tempDir, specDirName(CurrentSpecReport().FullText()))
+		}
+		paths, err := s.timeline.Dump(timelineDir)
+		Expect(err).NotTo(HaveOccurred())
+		for _, path := range paths {
+			AddReportEntry(filepath.Base(path), relativeToArtifacts(path), ReportEntryVisibilityFailureOrVerbose)
+		}
+	})
+}
+
+// Timeline returns the timeline of the running spec. When the spec fails,
+// the timeline is filled with the output of every component and written to
+// timeline.txt and timeline.json; specs add BBS events to it with WatchBBS.
+func (s *Suite) Timeline() *timeline.Timeline {
+	return s.timeline
+}
+
+// writeRunnerLogs writes the output of each runner to <name>.log in dir, and
+// anything it wrote to stderr to <name>.stderr.log. Runners that were started
+// more than once in the spec get a -2, -3, ... suffix after the first. It
+// returns the paths of the files written.
+func writeRunnerLogs(dir string, runners []world.ComponentRunner) ([]string, error) {
+	paths := []string{}
+	started := map[string]int{}
+	for _, runner := range runners {
+		name := specDirName(runner.Name)
+		started[name]++
+		if started[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, started[name])
+		}
+
+		outputs := []struct {
+			suffix   string
+			contents []byte
+		}{
+			{".log", runner.Runner.Buffer().Contents()},
+			{".stderr.log", runner.Runner.Err().Contents()},
+		}
+		for _, output := range outputs {
+			if output.suffix != ".log" && len(output.contents) == 0 {
+				continue
+			}
+
+			path := filepath.Join(dir, name+output.suffix)
+			err := os.WriteFile(path, output.contents, 0644)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// artifactsDir is where specs write their artifacts: $INIGO_ARTIFACTS_DIR or,
+// if that is unset, the directory of the ginkgo JSON or JUnit report, which
+// ginkgo puts in --output-dir. It is empty if there is neither.
+func artifactsDir() string {
+	if dir := os.Getenv("INIGO_ARTIFACTS_DIR"); dir != "" {
+		return dir
+	}
+
+	_, reporterConfig := GinkgoConfiguration()
+	for _, report := range []string{reporterConfig.JSONReport, reporterConfig.JUnitReport} {
+		if report != "" {
+			return filepath.Dir(report)
+		}
+	}
+	return ""
+}
+
+// specArtifactsDir returns the directory of the spec's artifacts, named
+// after the spec and the ginkgo process it runs on.
+func specArtifactsDir(report SpecReport) string {
+	dir := artifactsDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s-%d", specDirName(report.FullText()), GinkgoParallelProcess()))
+}
+
+// relativeToArtifacts returns path relative to the artifacts directory, so
+// that links in the report still work when the directory is downloaded.
+func relativeToArtifacts(path string) string {
+	relative, err := filepath.Rel(artifactsDir(), path)
+	if err != nil {
+		return path
+	}
+	return relative
+}
+
+// specDirName turns the text of a spec into a directory name.
+func specDirName(text string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, text)
+
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	return name
+}
@@ -0,0 +1,336 @@
+// Package suite bootstraps the inigo integration suites. A suite declares the
+// executables and lifecycles it runs; the package compiles them on the first
+// ginkgo process, hands them to every process, and gives each process its own
+// addresses, certificate authority and ComponentMaker:
+//
+//	var testSuite = suite.New(suite.Config{
+//		Name:        "executor",
+//		Executables: []suite.Executable{suite.Garden},
+//	})
+//
+//	var _ = testSuite.Register(&componentMaker)
+package suite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/portauthority"
+	"code.cloudfoundry.org/inigo/helpers/timeline"
+	"code.cloudfoundry.org/inigo/world"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+// Executable describes a component compiled for a suite.
+type Executable struct {
+	// Name is the key of the executable in world.BuiltExecutables.
+	Name    string
+	Package string
+	Args    []string
+
+	// DirEnv names the environment variable holding the directory to build
+	// from, for components that are not dependencies of inigo.
+	DirEnv string
+
+	// Env is set, as KEY=VALUE pairs, while the executable is built.
+	Env []string
+}
+
+// The executables of the Diego components run by the suites.
+var (
+	Garden       = Executable{Name: "garden", Package: "./cmd/gdn", Args: []string{"-race", "-a", "-tags", "daemon"}, DirEnv: "GARDEN_GOPATH"}
+	Auctioneer   = Executable{Name: "auctioneer", Package: "code.cloudfoundry.org/auctioneer/cmd/auctioneer", Args: []string{"-race"}}
+	Rep          = Executable{Name: "rep", Package: "code.cloudfoundry.org/rep/cmd/rep", Args: []string{"-race"}}
+	BBS          = Executable{Name: "bbs", Package: "code.cloudfoundry.org/bbs/cmd/bbs", Args: []string{"-race"}}
+	Locket       = Executable{Name: "locket", Package: "code.cloudfoundry.org/locket/cmd/locket", Args: []string{"-race"}}
+	FileServer   = Executable{Name: "file-server", Package: "code.cloudfoundry.org/fileserver/cmd/file-server", Args: []string{"-race"}}
+	RouteEmitter = Executable{Name: "route-emitter", Package: "code.cloudfoundry.org/route-emitter/cmd/route-emitter", Args: []string{"-race"}}
+	Router       = Executable{Name: "router", Package: "code.cloudfoundry.org/gorouter/cmd/gorouter", Args: []string{"-race"}, DirEnv: "ROUTER_GOPATH"}
+	RoutingAPI   = Executable{Name: "routing-api", Package: "code.cloudfoundry.org/routing-api/cmd/routing-api", Args: []string{"-race"}}
+	SSHProxy     = Executable{Name: "ssh-proxy", Package: "code.cloudfoundry.org/diego-ssh/cmd/ssh-proxy", Args: []string{"-race"}}
+	SSHD         = Executable{Name: "sshd", Package: "code.cloudfoundry.org/diego-ssh/cmd/sshd", Args: []string{"-a", "-installsuffix", "static"}, Env: []string{"CGO_ENABLED=0"}}
+	LocalDriver  = Executable{Name: "local-driver", Package: "code.cloudfoundry.org/localdriver/cmd/localdriver", Args: []string{"-race"}}
+)
+
+// Config declares what a suite needs compiled.
+type Config struct {
+	// Name prefixes the suite's temporary directories.
+	Name string
+
+	Executables []Executable
+	Lifecycles  []string
+
+	// Healthcheck compiles the healthcheck executable into a directory of
+	// its own, for world.BuiltArtifacts.Healthcheck.
+	Healthcheck bool
+	// V0Executables replace the executables of the same name in
+	// V0ComponentMaker, so that an upgrade starts from an older release. They
+	// are built like Executables, typically from that release's sources
+	// through DirEnv.
+	V0Executables []Executable
+
+	// FakeGarden runs the suite against componentMaker.FakeGarden() instead
+	// of Guardian. Start skips the GrootFS setup, and the ComponentMaker needs
+	// neither the Garden binaries nor root.
+	FakeGarden bool
+}
+
+// Suite compiles the artifacts of a Config and builds a ComponentMaker for
+// every ginkgo process.
+type Suite struct {
+	config Config
+
+	buildDir         string
+	tempDir          string
+	artifacts        world.BuiltArtifacts
+	v0Executables    world.BuiltExecutables
+	addresses        world.ComponentAddresses
+	allocator        portauthority.PortAllocator
+	certAuthority    certauthority.CertAuthority
+	componentMaker   world.ComponentMaker
+	v0ComponentMaker world.ComponentMaker
+	timeline         *timeline.Timeline
+}
+
+// New returns a Suite that builds the artifacts declared by config.
+func New(config Config) *Suite {
+	return &Suite{config: config}
+}
+
+// Register sets up the suite's SynchronizedBeforeSuite, which stores the
+// process's ComponentMaker in componentMaker, its SynchronizedAfterSuite, a
+// BeforeEach that captures the log of each component and the spec's timeline,
+// and an AfterEach that reports the component configs of failed specs.
+func (s *Suite) Register(componentMaker *world.ComponentMaker) bool {
+	SynchronizedBeforeSuite(s.Build, func(payload []byte) {
+		*componentMaker = s.Start(payload)
+	})
+	BeforeEach(s.CaptureComponentLogs)
+	AfterEach(s.ReportConfigs)
+	SynchronizedAfterSuite(s.Stop, s.Cleanup)
+	return true
+}
+
+// Build compiles the suite's artifacts and returns them encoded for Start.
+// It runs on the first ginkgo process only.
+func (s *Suite) Build() []byte {
+	s.buildDir = world.TempDir(s.config.Name + "-build")
+
+	buildCache := world.BuildCacheFromEnv()
+	artifacts, err := BuildArtifacts(buildCache, s.buildDir, s.config, GinkgoWriter)
+	Expect(err).NotTo(HaveOccurred())
+
+	v0Executables := world.BuiltExecutables{}
+	for _, executable := range s.config.V0Executables {
+		path, err := build(buildCache, executable)
+		Expect(err).NotTo(HaveOccurred(), "building v0 %s", executable.Name)
+		v0Executables[executable.Name] = path
+	}
+
+	payload, err := json.Marshal(builtPayload{
+		Artifacts:     artifacts,
+		V0Executables: v0Executables,
+	})
+	Expect(err).NotTo(HaveOccurred())
+	return payload
+}
+
+// builtPayload is what Build hands to Start on every ginkgo process.
+type builtPayload struct {
+	Artifacts     world.BuiltArtifacts
+	V0Executables world.BuiltExecutables
+}
+
+// Start decodes the artifacts built by Build and returns a ComponentMaker
+// for this ginkgo process, after running its Setup.
+func (s *Suite) Start(payload []byte) world.ComponentMaker {
+	var built builtPayload
+	err := json.Unmarshal(payload, &built)
+	Expect(err).NotTo(HaveOccurred())
+	artifacts := built.Artifacts
+
+	s.tempDir = world.TempDir(s.config.Name)
+
+	allocator, err := portauthority.NewSharedPortAllocator(portauthority.DefaultStateDir(), 10000, 32767)
+	Expect(err).NotTo(HaveOccurred())
+
+	addresses := world.MakeComponentAddresses(allocator)
+
+	certDepot := world.TempDirWithParent(s.tempDir, "cert-depot")
+	certAuthority, err := certauthority.NewCertAuthority(certDepot, "ca")
+	Expect(err).NotTo(HaveOccurred())
+
+	s.artifacts = artifacts
+	s.v0Executables = built.V0Executables
+	s.addresses = addresses
+	s.allocator = allocator
+	s.certAuthority = certAuthority
+
+	factory, err := world.NewComponentFactory(artifacts, addresses, allocator, certAuthority, world.FactoryOptions{
+		ParallelProcess: GinkgoParallelProcess(),
+		Output:          GinkgoWriter,
+		FakeGarden:      s.config.FakeGarden,
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	s.componentMaker = world.WrapComponentFactory(factory)
+	if !s.config.FakeGarden {
+		s.componentMaker.Setup()
+	}
+	return s.componentMaker
+}
+
+// V0ComponentMaker returns a ComponentMaker that launches this process's
+// components with legacy command line flags, running the V0Executables in
+// place of the Executables of the same name. It shares the addresses and
+// certificate authority of the process's ComponentMaker, so that components
+// from either can be mixed in a cluster, and relies on that ComponentMaker
+// for the GrootFS stores.
+func (s *Suite) V0ComponentMaker() world.ComponentMaker {
+	if s.v0ComponentMaker == nil {
+		artifacts := s.artifacts
+		artifacts.Executables = world.BuiltExecutables{}
+		for name, path := range s.artifacts.Executables {
+			artifacts.Executables[name] = path
+		}
+		for name, path := range s.v0Executables {
+			artifacts.Executables[name] = path
+		}
+
+		factory, err := world.NewV0ComponentFactory(artifacts, s.addresses, s.allocator, s.certAuthority, world.FactoryOptions{
+			ParallelProcess: GinkgoParallelProcess(),
+			Output:          GinkgoWriter,
+			TempDir:         s.tempDir,
+			FakeGarden:      s.config.FakeGarden,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		s.v0ComponentMaker = world.WrapComponentFactory(factory)
+	}
+	return s.v0ComponentMaker
+}
+
+// TempDir returns the temporary directory of this ginkgo process. It is
+// removed by Stop.
+func (s *Suite) TempDir() string {
+	return s.tempDir
+}
+
+// Stop tears down this process's ComponentMaker and removes its temporary
+// directory.
+func (s *Suite) Stop() {
+	if s.componentMaker != nil {
+		s.componentMaker.Teardown()
+	}
+
+	if s.tempDir != "" {
+		deleteTempDir := func() error { return os.RemoveAll(s.tempDir) }
+		Eventually(deleteTempDir).Should(Succeed())
+	}
+}
+
+// Cleanup removes the artifacts built by Build, once every process has
+// stopped. It runs on the first ginkgo process only.
+func (s *Suite) Cleanup() {
+	if s.buildDir != "" {
+		Expect(os.RemoveAll(s.buildDir)).To(Succeed())
+	}
+	gexec.CleanupBuildArtifacts()
+}
+
+// BuildArtifacts compiles the artifacts declared by config through
+// buildCache, with lifecycles and the healthcheck placed in buildDir. The
+// output of packaging the lifecycles is written to output. It does not need
+// ginkgo, so that tools running a world outside of a suite can use it.
+func BuildArtifacts(buildCache world.BuildCache, buildDir string, config Config, output io.Writer) (world.BuiltArtifacts, error) {
+	artifacts := world.BuiltArtifacts{
+		Executables: world.BuiltExecutables{},
+		Lifecycles:  world.BuiltLifecycles{},
+	}
+
+	for _, executable := range config.Executables {
+		path, err := build(buildCache, executable)
+		if err != nil {
+			return world.BuiltArtifacts{}, fmt.Errorf("building %s: %w", executable.Name, err)
+		}
+		artifacts.Executables[executable.Name] = path
+	}
+
+	for _, lifecycle := range config.Lifecycles {
+		err := artifacts.Lifecycles.Build(buildCache, lifecycle, buildDir, output)
+		if err != nil {
+			return world.BuiltArtifacts{}, fmt.Errorf("building %s: %w", lifecycle, err)
+		}
+	}
+
+	if config.Healthcheck {
+		healthcheckDir, err := buildHealthcheck(buildCache, buildDir)
+		if err != nil {
+			return world.BuiltArtifacts{}, fmt.Errorf("building healthcheck: %w", err)
+		}
+		artifacts.Healthcheck = healthcheckDir
+	}
+
+	return artifacts, nil
+}
+
+func buildHealthcheck(buildCache world.BuildCache, buildDir string) (string, error) {
+	healthcheckDir, err := world.NewTempDirWithParent(buildDir, "healthcheck")
+	if err != nil {
+		return "", err
+	}
+
+	name := "healthcheck"
+	if runtime.GOOS == "windows" {
+		name = "healthcheck.exe"
+	}
+
+	err = buildCache.BuildTo(filepath.Join(healthcheckDir, name), "code.cloudfoundry.org/healthcheck/cmd/healthcheck", "-race")
+	if err != nil {
+		return "", err
+	}
+	return healthcheckDir, nil
+}
+
+// build compiles the executable from its directory and with its environment,
+// restoring both afterwards.
+func build(buildCache world.BuildCache, executable Executable) (string, error) {
+	if executable.DirEnv != "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+
+		err = os.Chdir(os.Getenv(executable.DirEnv))
+		if err != nil {
+			return "", err
+		}
+		// #nosec G104 - the directory was the working directory a moment ago
+		defer os.Chdir(cwd)
+	}
+
+	for _, env := range executable.Env {
+		key, value, _ := strings.Cut(env, "=")
+
+		previous, found := os.LookupEnv(key)
+		if found {
+			defer os.Setenv(key, previous)
+		} else {
+			defer os.Unsetenv(key)
+		}
+
+		err := os.Setenv(key, value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return buildCache.Build(executable.Package, executable.Args...)
+}
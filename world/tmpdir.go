@@ -7,21 +7,33 @@ import (
 )
 
 func TempDir(prefix string) string {
-	tmpDir, err := os.MkdirTemp(os.TempDir(), prefix)
+	tmpDir, err := NewTempDir(prefix)
 	Expect(err).NotTo(HaveOccurred())
 
-	err = os.Chmod(tmpDir, 0755)
+	return tmpDir
+}
+
+func TempDirWithParent(parentDir string, prefix string) string {
+	tmpDir, err := NewTempDirWithParent(parentDir, prefix)
 	Expect(err).NotTo(HaveOccurred())
 
 	return tmpDir
 }
 
-func TempDirWithParent(parentDir string, prefix string) string {
+func NewTempDir(prefix string) (string, error) {
+	return NewTempDirWithParent(os.TempDir(), prefix)
+}
+
+func NewTempDirWithParent(parentDir string, prefix string) (string, error) {
 	tmpDir, err := os.MkdirTemp(parentDir, prefix)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return "", err
+	}
 
 	err = os.Chmod(tmpDir, 0755)
-	Expect(err).NotTo(HaveOccurred())
+	if err != nil {
+		return "", err
+	}
 
-	return tmpDir
+	return tmpDir, nil
 }
@@ -0,0 +1,432 @@
+package world
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+
+	auctioneerconfig "code.cloudfoundry.org/auctioneer/cmd/auctioneer/config"
+	bbsconfig "code.cloudfoundry.org/bbs/cmd/bbs/config"
+	sshproxyconfig "code.cloudfoundry.org/diego-ssh/cmd/ssh-proxy/config"
+	"code.cloudfoundry.org/guardian/gqt/runner"
+	locketconfig "code.cloudfoundry.org/locket/cmd/locket/config"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	routeemitterconfig "code.cloudfoundry.org/route-emitter/cmd/route-emitter/config"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+	"github.com/tedsuo/ifrit/grouper"
+)
+
+const (
+	SQLComponent          = "sql"
+	NATSComponent         = "nats"
+	GardenComponent       = "garden"
+	LocketComponent       = "locket"
+	BBSComponent          = "bbs"
+	AuctioneerComponent   = "auctioneer"
+	RepComponent          = "rep"
+	RouteEmitterComponent = "route-emitter"
+	FileServerComponent   = "file-server"
+	RouterComponent       = "router"
+	SSHProxyComponent     = "ssh-proxy"
+)
+
+// Topology describes which components make up a Diego cluster and how each of
+// them is configured. Overrides are keyed by the JSON field names of the
+// component's config and are applied on top of the ComponentMaker defaults.
+//
+//	components:
+//	  sql: {}
+//	  nats: {}
+//	  locket: {}
+//	  bbs:
+//	    overrides:
+//	      converge_repeat_interval: 1s
+//	  rep:
+//	    count: 2
+type Topology struct {
+	Components map[string]ComponentSpec `json:"components" yaml:"components"`
+}
+
+type ComponentSpec struct {
+	Count     int                    `json:"count,omitempty" yaml:"count,omitempty"`
+	Overrides map[string]interface{} `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+}
+
+// scalableComponents may be started more than once in a single cluster.
+var scalableComponents = map[string]bool{
+	RepComponent:          true,
+	RouteEmitterComponent: true,
+}
+
+// configurableComponents accept config overrides.
+var configurableComponents = map[string]bool{
+	GardenComponent:       true,
+	LocketComponent:       true,
+	BBSComponent:          true,
+	AuctioneerComponent:   true,
+	RepComponent:          true,
+	RouteEmitterComponent: true,
+	SSHProxyComponent:     true,
+}
+
+var knownComponents = map[string]bool{
+	SQLComponent:          true,
+	NATSComponent:         true,
+	GardenComponent:       true,
+	LocketComponent:       true,
+	BBSComponent:          true,
+	AuctioneerComponent:   true,
+	RepComponent:          true,
+	RouteEmitterComponent: true,
+	FileServerComponent:   true,
+	RouterComponent:       true,
+	SSHProxyComponent:     true,
+}
+
+// LoadTopology reads a Topology from a YAML or JSON file.
+func LoadTopology(path string) (Topology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	var topology Topology
+	err = yaml.Unmarshal(data, &topology)
+	if err != nil {
+		return Topology{}, fmt.Errorf("parsing topology %s: %w", path, err)
+	}
+
+	for name, spec := range topology.Components {
+		if spec.Overrides != nil {
+			spec.Overrides = normalizeYAMLMap(spec.Overrides)
+			topology.Components[name] = spec
+		}
+	}
+
+	return topology, topology.Validate()
+}
+
+// Validate returns an error if the topology names an unknown component, asks
+// for several instances of a component that can only run once, or overrides
+// the config of a component that has no config.
+func (t Topology) Validate() error {
+	for _, name := range t.componentNames() {
+		spec := t.Components[name]
+		if !knownComponents[name] {
+			return fmt.Errorf("unknown component %q", name)
+		}
+		if spec.Count < 0 {
+			return fmt.Errorf("component %q has a negative count", name)
+		}
+		if spec.Count > 1 && !scalableComponents[name] {
+			return fmt.Errorf("component %q cannot be started more than once", name)
+		}
+		if len(spec.Overrides) > 0 && !configurableComponents[name] {
+			return fmt.Errorf("component %q does not support config overrides", name)
+		}
+	}
+
+	if t.count(RepComponent) > 0 {
+		err := checkRepIndex(t.count(RepComponent) - 1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t Topology) has(name string) bool {
+	_, ok := t.Components[name]
+	return ok
+}
+
+func (t Topology) count(name string) int {
+	spec, ok := t.Components[name]
+	if !ok {
+		return 0
+	}
+	if spec.Count == 0 {
+		return 1
+	}
+	return spec.Count
+}
+
+func (t Topology) componentNames() []string {
+	names := make([]string, 0, len(t.Components))
+	for name := range t.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Cluster holds the runners for every component of a Topology. Components
+// that are not part of the topology are left nil.
+type Cluster struct {
+	SQL                 ifrit.Runner
+	NATS                ifrit.Runner
+	Garden              *runner.GardenRunner
+	Locket              ifrit.Runner
+	BBS                 *ginkgomon.Runner
+	Auctioneer          *ginkgomon.Runner
+	Reps                []*ginkgomon.Runner
+	RouteEmitters       []*ginkgomon.Runner
+	FileServer          ifrit.Runner
+	FileServerStaticDir string
+	Router              *ginkgomon.Runner
+	SSHProxy            ifrit.Runner
+
+	stages grouper.Members
+}
+
+// NewCluster builds the runners described by the topology. Components are
+// grouped into stages that are started in order: sql, nats and garden first,
+// then locket, then bbs, and finally everything that talks to bbs. Each
+// stage waits for its members to be ready according to the factory's
+// readiness mode.
+func NewCluster(factory ComponentFactory, topology Topology) (*Cluster, error) {
+	err := topology.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = topology.validateOverrides()
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &Cluster{}
+
+	initialServices := grouper.Members{}
+	if topology.has(SQLComponent) {
+		cluster.SQL = factory.SQL()
+		initialServices = append(initialServices, grouper.Member{Name: SQLComponent, Runner: factory.WithReadinessCheck(cluster.SQL)})
+	}
+	if topology.has(NATSComponent) {
+		cluster.NATS, err = factory.NATS()
+		if err != nil {
+			return nil, err
+		}
+		initialServices = append(initialServices, grouper.Member{Name: NATSComponent, Runner: factory.WithReadinessCheck(cluster.NATS)})
+	}
+	if topology.has(GardenComponent) {
+		cluster.Garden, err = factory.Garden(func(cfg *runner.GdnRunnerConfig) {
+			applyOverrides(topology.Components[GardenComponent].Overrides, cfg)
+		})
+		if err != nil {
+			return nil, err
+		}
+		initialServices = append(initialServices, grouper.Member{Name: GardenComponent, Runner: factory.WithReadinessCheck(cluster.Garden)})
+	}
+	cluster.addStage("initial-services", initialServices)
+
+	if topology.has(LocketComponent) {
+		cluster.Locket, err = factory.Locket(func(cfg *locketconfig.LocketConfig) {
+			applyOverrides(topology.Components[LocketComponent].Overrides, cfg)
+		})
+		if err != nil {
+			return nil, err
+		}
+		cluster.addStage(LocketComponent, grouper.Members{{Name: LocketComponent, Runner: factory.WithReadinessCheck(cluster.Locket)}})
+	}
+
+	if topology.has(BBSComponent) {
+		cluster.BBS, err = factory.BBS(func(cfg *bbsconfig.BBSConfig) {
+			applyOverrides(topology.Components[BBSComponent].Overrides, cfg)
+		})
+		if err != nil {
+			return nil, err
+		}
+		cluster.addStage(BBSComponent, grouper.Members{{Name: BBSComponent, Runner: factory.WithReadinessCheck(cluster.BBS)}})
+	}
+
+	components := grouper.Members{}
+	if topology.has(AuctioneerComponent) {
+		cluster.Auctioneer, err = factory.Auctioneer(func(cfg *auctioneerconfig.AuctioneerConfig) {
+			applyOverrides(topology.Components[AuctioneerComponent].Overrides, cfg)
+		})
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, grouper.Member{Name: AuctioneerComponent, Runner: factory.WithReadinessCheck(cluster.Auctioneer)})
+	}
+	for i := 0; i < topology.count(RepComponent); i++ {
+		rep, err := factory.RepN(i, func(cfg *repconfig.RepConfig) {
+			applyOverrides(topology.Components[RepComponent].Overrides, cfg)
+		})
+		if err != nil {
+			return nil, err
+		}
+		cluster.Reps = append(cluster.Reps, rep)
+		components = append(components, grouper.Member{Name: RepComponent + "-" + strconv.Itoa(i), Runner: factory.WithReadinessCheck(rep)})
+	}
+	for i := 0; i < topology.count(RouteEmitterComponent); i++ {
+		routeEmitter, err := factory.RouteEmitterN(i, func(cfg *routeemitterconfig.RouteEmitterConfig) {
+			applyOverrides(topology.Components[RouteEmitterComponent].Overrides, cfg)
+		})
+		if err != nil {
+			return nil, err
+		}
+		cluster.RouteEmitters = append(cluster.RouteEmitters, routeEmitter)
+		components = append(components, grouper.Member{Name: RouteEmitterComponent + "-" + strconv.Itoa(i), Runner: factory.WithReadinessCheck(routeEmitter)})
+	}
+	if topology.has(FileServerComponent) {
+		cluster.FileServer, cluster.FileServerStaticDir, err = factory.FileServer()
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, grouper.Member{Name: FileServerComponent, Runner: factory.WithReadinessCheck(cluster.FileServer)})
+	}
+	if topology.has(RouterComponent) {
+		cluster.Router, err = factory.Router()
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, grouper.Member{Name: RouterComponent, Runner: factory.WithReadinessCheck(cluster.Router)})
+	}
+	if topology.has(SSHProxyComponent) {
+		cluster.SSHProxy, err = factory.SSHProxy(func(cfg *sshproxyconfig.SSHProxyConfig) {
+			applyOverrides(topology.Components[SSHProxyComponent].Overrides, cfg)
+		})
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, grouper.Member{Name: SSHProxyComponent, Runner: factory.WithReadinessCheck(cluster.SSHProxy)})
+	}
+	cluster.addStage("components", components)
+
+	return cluster, nil
+}
+
+// NewClusterFromFile loads the topology at path and builds its runners.
+func NewClusterFromFile(factory ComponentFactory, path string) (*Cluster, error) {
+	topology, err := LoadTopology(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCluster(factory, topology)
+}
+
+// MakeCluster is NewCluster for use in specs.
+func MakeCluster(maker ComponentMaker, topology Topology) *Cluster {
+	cluster, err := NewCluster(maker.Factory(), topology)
+	Expect(err).NotTo(HaveOccurred())
+	return cluster
+}
+
+// MakeClusterFromFile is NewClusterFromFile for use in specs.
+func MakeClusterFromFile(maker ComponentMaker, path string) *Cluster {
+	cluster, err := NewClusterFromFile(maker.Factory(), path)
+	Expect(err).NotTo(HaveOccurred())
+	return cluster
+}
+
+// Runner returns an ifrit.Runner that starts every stage of the cluster in
+// order, starting the members of each stage in parallel.
+func (c *Cluster) Runner() ifrit.Runner {
+	return grouper.NewOrdered(os.Kill, c.stages)
+}
+
+// Rep returns the first rep of the cluster.
+func (c *Cluster) Rep() *ginkgomon.Runner {
+	Expect(c.Reps).NotTo(BeEmpty(), "cluster has no reps")
+	return c.Reps[0]
+}
+
+// RouteEmitter returns the first route-emitter of the cluster.
+func (c *Cluster) RouteEmitter() *ginkgomon.Runner {
+	Expect(c.RouteEmitters).NotTo(BeEmpty(), "cluster has no route-emitters")
+	return c.RouteEmitters[0]
+}
+
+func (c *Cluster) addStage(name string, members grouper.Members) {
+	if len(members) == 0 {
+		return
+	}
+	c.stages = append(c.stages, grouper.Member{Name: name, Runner: grouper.NewParallel(os.Kill, members)})
+}
+
+// validateOverrides returns an error if the overrides of any component cannot
+// be decoded into its config.
+func (t Topology) validateOverrides() error {
+	configs := map[string]func() interface{}{
+		GardenComponent:       func() interface{} { return &runner.GdnRunnerConfig{} },
+		LocketComponent:       func() interface{} { return &locketconfig.LocketConfig{} },
+		BBSComponent:          func() interface{} { return &bbsconfig.BBSConfig{} },
+		AuctioneerComponent:   func() interface{} { return &auctioneerconfig.AuctioneerConfig{} },
+		RepComponent:          func() interface{} { return &repconfig.RepConfig{} },
+		RouteEmitterComponent: func() interface{} { return &routeemitterconfig.RouteEmitterConfig{} },
+		SSHProxyComponent:     func() interface{} { return &sshproxyconfig.SSHProxyConfig{} },
+	}
+
+	for _, name := range t.componentNames() {
+		newConfig, ok := configs[name]
+		if !ok {
+			continue
+		}
+
+		err := applyOverrides(t.Components[name].Overrides, newConfig())
+		if err != nil {
+			return fmt.Errorf("component %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// applyOverrides merges the overrides into cfg by round-tripping them through
+// the JSON representation of the config. NewCluster validates the overrides
+// up front, so the modify funcs that call it ignore the error.
+func applyOverrides(overrides map[string]interface{}, cfg interface{}) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(data, cfg)
+	if err != nil {
+		return fmt.Errorf("invalid overrides %s: %w", string(data), err)
+	}
+	return nil
+}
+
+// normalizeYAMLMap converts the map[interface{}]interface{} values produced by
+// yaml.v2 into map[string]interface{} so that they can be encoded as JSON.
+func normalizeYAMLMap(in map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(in))
+	for key, value := range in {
+		out[key] = normalizeYAMLValue(value)
+	}
+	return out
+}
+
+func normalizeYAMLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[fmt.Sprint(key)] = normalizeYAMLValue(value)
+		}
+		return out
+	case map[string]interface{}:
+		return normalizeYAMLMap(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = normalizeYAMLValue(value)
+		}
+		return out
+	default:
+		return value
+	}
+}
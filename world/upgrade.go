@@ -0,0 +1,342 @@
+package world
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager/v3"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	"code.cloudfoundry.org/tlsconfig"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+// upgradeCheckInterval is how often an Upgrade runs its checks while a
+// component is being replaced.
+const upgradeCheckInterval = 500 * time.Millisecond
+
+// UpgradeCheck returns an error if the cluster is unhealthy while, or after,
+// the named component is replaced.
+type UpgradeCheck func(component string) error
+
+// Upgrade replaces the BBS, auctioneer, reps and route-emitter of a running
+// cluster one at a time, moving them from one ComponentFactory to another.
+// Both factories must share the same addresses, so that every replacement
+// listens where its predecessor did and the BBS keeps using the same
+// database. The infrastructure the components depend on (sql, nats, garden,
+// locket, file-server and router) is left to the caller.
+//
+// Reps are evacuated before they are replaced, so that their LRPs move to
+// the remaining cells. An auction requested between the BBS and auctioneer
+// steps is only placed once the BBS next converges.
+//
+// The BBS data is only migrated if the factory being upgraded from runs an
+// older BBS executable; if both factories run the same executables, only the
+// command lines of the components change. Suites give their
+// V0ComponentMaker older executables through suite.Config.V0Executables.
+type Upgrade struct {
+	from ComponentFactory
+	to   ComponentFactory
+	reps int
+
+	logger     lager.Logger
+	bbsClient  bbs.InternalClient
+	httpClient *http.Client
+
+	checks    []UpgradeCheck
+	processes map[string]ifrit.Process
+	repAddrs  map[string]string
+}
+
+// NewUpgrade returns an Upgrade of a cluster with the given number of reps
+// from one factory's components to the other's. The checks run repeatedly
+// while each component is replaced and once after, in addition to a check
+// that every desired LRP and task in the BBS survives each step.
+func NewUpgrade(logger lager.Logger, from, to ComponentFactory, reps int, checks ...UpgradeCheck) (*Upgrade, error) {
+	if reps < 1 {
+		return nil, fmt.Errorf("an upgrade needs at least one rep, got %d", reps)
+	}
+
+	bbsClient, err := to.BBSClient()
+	if err != nil {
+		return nil, err
+	}
+
+	repSSL := to.RepSSLConfig()
+	tlsConfig, err := tlsconfig.Build(
+		tlsconfig.WithInternalServiceDefaults(),
+		tlsconfig.WithIdentityFromFile(repSSL.ServerCert, repSSL.ServerKey),
+	).Client(tlsconfig.WithAuthorityFromFile(repSSL.CACert))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Upgrade{
+		from: from,
+		to:   to,
+		reps: reps,
+
+		logger:    logger.Session("upgrade"),
+		bbsClient: bbsClient,
+		httpClient: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+
+		checks:    checks,
+		processes: map[string]ifrit.Process{},
+		repAddrs:  map[string]string{},
+	}, nil
+}
+
+// MakeUpgrade is NewUpgrade for use in specs.
+func MakeUpgrade(logger lager.Logger, from, to ComponentMaker, reps int, checks ...UpgradeCheck) *Upgrade {
+	upgrade, err := NewUpgrade(logger, from.Factory(), to.Factory(), reps, checks...)
+	Expect(err).NotTo(HaveOccurred())
+	return upgrade
+}
+
+// Components returns the components in the order they are replaced.
+func (u *Upgrade) Components() []string {
+	components := []string{BBSComponent, AuctioneerComponent}
+	for n := 0; n < u.reps; n++ {
+		components = append(components, RepComponent+"-"+strconv.Itoa(n))
+	}
+	return append(components, RouteEmitterComponent)
+}
+
+// Start starts every component with the factory being upgraded from.
+func (u *Upgrade) Start() error {
+	for _, component := range u.Components() {
+		err := u.start(u.from, component)
+		if err != nil {
+			u.Stop()
+			return err
+		}
+	}
+	return nil
+}
+
+// Run replaces every component in turn. It stops at the first component that
+// fails to start or whose checks fail, leaving the rest of the cluster
+// running on the factory being upgraded from.
+func (u *Upgrade) Run() error {
+	before, err := snapshotBBS(u.logger, u.bbsClient)
+	if err != nil {
+		return fmt.Errorf("reading the bbs before upgrading: %w", err)
+	}
+
+	for _, component := range u.Components() {
+		err := u.replace(component)
+		if err != nil {
+			return fmt.Errorf("upgrading %s: %w", component, err)
+		}
+
+		after, err := snapshotBBS(u.logger, u.bbsClient)
+		if err != nil {
+			return fmt.Errorf("reading the bbs after upgrading %s: %w", component, err)
+		}
+
+		err = before.missingFrom(after)
+		if err != nil {
+			return fmt.Errorf("upgrading %s: %w", component, err)
+		}
+		before = after
+	}
+	return nil
+}
+
+// Stop stops every component the upgrade started.
+func (u *Upgrade) Stop() {
+	for component, process := range u.processes {
+		process.Signal(os.Interrupt)
+		<-process.Wait()
+		delete(u.processes, component)
+	}
+}
+
+func (u *Upgrade) replace(component string) error {
+	u.logger.Info("replacing", lager.Data{"component": component})
+
+	stepErrs := make(chan error, 1)
+	go func() {
+		stepErrs <- u.step(component)
+	}()
+
+	ticker := time.NewTicker(upgradeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-stepErrs:
+			if err != nil {
+				return err
+			}
+			return u.check(component)
+
+		case <-ticker.C:
+			err := u.check(component)
+			if err != nil {
+				// wait for the step, so that its process is tracked and Stop
+				// can clean it up
+				<-stepErrs
+				return err
+			}
+		}
+	}
+}
+
+func (u *Upgrade) step(component string) error {
+	process, ok := u.processes[component]
+	if !ok {
+		return fmt.Errorf("%s is not running, the upgrade must be started first", component)
+	}
+	delete(u.processes, component)
+
+	if addr, ok := u.repAddrs[component]; ok {
+		err := u.evacuate(addr)
+		if err != nil {
+			u.logger.Error("failed-to-evacuate", err, lager.Data{"component": component})
+			process.Signal(os.Interrupt)
+		}
+	} else {
+		process.Signal(os.Interrupt)
+	}
+	<-process.Wait()
+
+	return u.start(u.to, component)
+}
+
+func (u *Upgrade) check(component string) error {
+	for _, check := range u.checks {
+		err := check(component)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *Upgrade) start(factory ComponentFactory, component string) error {
+	var runner ifrit.Runner
+	var err error
+
+	switch component {
+	case BBSComponent:
+		runner, err = factory.BBS()
+	case AuctioneerComponent:
+		runner, err = factory.Auctioneer()
+	case RouteEmitterComponent:
+		runner, err = factory.RouteEmitter()
+	default:
+		var n int
+		n, err = strconv.Atoi(component[len(RepComponent)+1:])
+		if err != nil {
+			return err
+		}
+		runner, err = factory.RepN(n, func(cfg *repconfig.RepConfig) {
+			u.repAddrs[component] = cfg.ListenAddr
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	process := ifrit.Background(factory.WithReadinessCheck(runner))
+	select {
+	case <-process.Ready():
+	case err := <-process.Wait():
+		return fmt.Errorf("%s exited before it was ready: %v", component, err)
+	}
+
+	u.processes[component] = process
+	return nil
+}
+
+// evacuate asks the rep listening on addr to evacuate its LRPs, after which
+// it exits.
+func (u *Upgrade) evacuate(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	// the rep's server certificate only has 127.0.0.1 as an IP SAN
+	resp, err := u.httpClient.Post("https://127.0.0.1:"+port+"/evacuate", "text/html", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("evacuating rep at %s: %s", addr, resp.Status)
+	}
+	return nil
+}
+
+// bbsSnapshot records the desired LRPs and tasks in the BBS.
+type bbsSnapshot struct {
+	desiredLRPs map[string]int32
+	tasks       map[string]models.Task_State
+}
+
+func snapshotBBS(logger lager.Logger, client bbs.InternalClient) (bbsSnapshot, error) {
+	snapshot := bbsSnapshot{
+		desiredLRPs: map[string]int32{},
+		tasks:       map[string]models.Task_State{},
+	}
+
+	desiredLRPs, err := client.DesiredLRPs(logger, "", models.DesiredLRPFilter{})
+	if err != nil {
+		return bbsSnapshot{}, err
+	}
+	for _, lrp := range desiredLRPs {
+		snapshot.desiredLRPs[lrp.ProcessGuid] = lrp.Instances
+	}
+
+	tasks, err := client.Tasks(logger, "")
+	if err != nil {
+		return bbsSnapshot{}, err
+	}
+	for _, task := range tasks {
+		snapshot.tasks[task.TaskGuid] = task.State
+	}
+
+	return snapshot, nil
+}
+
+// missingFrom returns an error naming the desired LRPs of s that are missing
+// from, or were scaled in, other, and the tasks of s that are missing from
+// other or whose state went backwards in it. Completed tasks may be missing,
+// as they expire or are resolved while the upgrade runs.
+func (s bbsSnapshot) missingFrom(other bbsSnapshot) error {
+	missing := []string{}
+	for guid, instances := range s.desiredLRPs {
+		if other.desiredLRPs[guid] != instances {
+			missing = append(missing, fmt.Sprintf("desired lrp %s (%d instances, now %d)", guid, instances, other.desiredLRPs[guid]))
+		}
+	}
+
+	for guid, state := range s.tasks {
+		otherState, ok := other.tasks[guid]
+		switch {
+		case !ok && state < models.Task_Completed:
+			missing = append(missing, fmt.Sprintf("task %s (%s, now missing)", guid, state))
+		case ok && otherState < state:
+			missing = append(missing, fmt.Sprintf("task %s (%s, now %s)", guid, state, otherState))
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("bbs data did not survive the upgrade: %v", missing)
+}